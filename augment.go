@@ -0,0 +1,77 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+// Augment is a monoid used to keep a summary of every subtree, such as the
+// total or the maximum of the values below it. Combine must be associative
+// with Identity as its identity, but it needn't be commutative as values are
+// always combined in key order.
+type Augment interface {
+    Identity() interface{}
+    Lift(value interface{}) interface{}
+    Combine(a interface{}, b interface{}) interface{}
+}
+
+// NewAugmentedTrie returns an empty trie that keeps every branch's aggregate
+// up to date as entries are added and removed
+func NewAugmentedTrie(augment Augment) *Trie {
+    t := NewTrie()
+    t.augment = augment
+    t.tree.aggregate = augment.Identity()
+    return t
+}
+
+func (this *Trie) refreshAggregate(t *branch) {
+    aggregate := this.augment.Identity()
+    if t.value != nil {
+        aggregate = this.augment.Lift(t.value)
+    }
+    eachChild(t, false, func(b byte, child *branch) bool {
+        aggregate = this.augment.Combine(aggregate, child.aggregate)
+        return true
+    })
+    t.aggregate = aggregate
+}
+
+// AggregatePrefix combines the values of every entry starting with prefix,
+// it's nil if the trie has no Augment
+func (this *Trie) AggregatePrefix(prefix string) interface{} {
+    if this.augment == nil {
+        return nil
+    }
+    this.lock.RLock()
+    defer this.lock.RUnlock()
+    t, off := this.tree, 0
+    for x := 0; x < len(prefix); x++ {
+        var ok bool
+        t, off, ok = this.step(t, off, prefix[x])
+        if !ok {
+            return this.augment.Identity()
+        }
+    }
+    // everything below t shares the prefix, even part way through a shortcut
+    return t.aggregate
+}
@@ -0,0 +1,89 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+
+type sumAugment struct{}
+
+func (this sumAugment) Identity() interface{} { return 0 }
+func (this sumAugment) Lift(value interface{}) interface{} { return value.(int) }
+func (this sumAugment) Combine(a interface{}, b interface{}) interface{} { return a.(int) + b.(int) }
+
+// keeps the keys in order, to check we combine in key order
+type concatAugment struct{}
+
+func (this concatAugment) Identity() interface{} { return "" }
+func (this concatAugment) Lift(value interface{}) interface{} { return value.(string) }
+func (this concatAugment) Combine(a interface{}, b interface{}) interface{} { return a.(string) + b.(string) }
+
+func TestAggregatePrefix(t *testing.T) {
+    trie := NewAugmentedTrie(sumAugment{})
+    trie.AddEntry("APPEAR", 3)
+    trie.AddEntry("APPEARANCE", 5)
+    trie.AddEntry("APPEARANCES", 7)
+    trie.AddEntry("APPEARED", 11)
+    trie.AddEntry("APPLE", 13)
+
+    sums := map[string]int {
+        "": 39,
+        "AP": 39,
+        "APPEAR": 26,
+        "APPEARANC": 12,
+        "APPEARANCE": 12,
+        "APPL": 13,
+        "APPLES": 0,
+        "B": 0,
+    }
+    for prefix, expected := range sums {
+        if sum := trie.AggregatePrefix(prefix).(int); sum != expected {
+            t.Errorf("Sum under %q was %d, expected %d", prefix, sum, expected)
+        }
+    }
+
+    trie.AddEntry("APPEARANCE", 1)
+    trie.RemoveEntry("APPEARED")
+    if sum := trie.AggregatePrefix("APPEAR").(int); sum != 11 {
+        t.Errorf("Sum not maintained through update and removal, got %d", sum)
+    }
+    trie.RemoveEntry("APPLE")
+    if sum := trie.AggregatePrefix("").(int); sum != 11 {
+        t.Errorf("Sum not maintained through collapse, got %d", sum)
+    }
+    if NewTrie().AggregatePrefix("") != nil {
+        t.Errorf("Aggregate without an augment")
+    }
+}
+
+func TestAggregateOrder(t *testing.T) {
+    trie := NewAugmentedTrie(concatAugment{})
+    for _, key := range []string {"b", "a", "ab", "+", "c", "你"} {
+        trie.AddEntry(key, key)
+    }
+    if all := trie.AggregatePrefix("").(string); all != "+aabbc你" {
+        t.Errorf("Values combined out of order: %q", all)
+    }
+}
@@ -0,0 +1,230 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "container/heap"
+    "container/list"
+    "sync"
+)
+
+type EvictionPolicy int
+
+const (
+    // EvictLRU drops the least recently used entry first
+    EvictLRU EvictionPolicy = iota
+    // EvictLFU drops the least frequently used entry first, the least
+    // recently used of those on a tie
+    EvictLFU
+)
+
+// rough cost of an entry on top of its key, covering the branch and the
+// bookkeeping here
+const boundedEntryOverhead = 128
+
+// BoundedTrie is a Trie that holds at most a set number of entries or an
+// approximate number of bytes, evicting entries by policy to make room. Both
+// adding an entry and a GetEntry hit count as a use of it.
+type BoundedTrie struct {
+    entries *Trie
+    policy EvictionPolicy
+    maxEntries int
+    maxBytes int
+    bytes int
+    tick uint64
+    recent *list.List // for LRU, most recent at the front
+    frequent boundedHeap // for LFU
+    tracked map[string]*boundedEntry
+    lock sync.Mutex
+}
+
+type boundedEntry struct {
+    key string
+    size int
+    uses int
+    last uint64
+    element *list.Element
+    index int
+}
+
+// NewBoundedTrie returns an empty trie holding up to maxEntries entries and
+// about maxBytes bytes, a limit of 0 meaning no limit
+func NewBoundedTrie(policy EvictionPolicy, maxEntries int, maxBytes int) *BoundedTrie {
+    return &BoundedTrie {
+        entries: NewTrie(),
+        policy: policy,
+        maxEntries: maxEntries,
+        maxBytes: maxBytes,
+        recent: list.New(),
+        tracked: make(map[string]*boundedEntry),
+    }
+}
+
+// entrySize guesses at the memory an entry takes up
+func entrySize(key string, value interface{}) int {
+    size := len(key) + boundedEntryOverhead
+    switch v := value.(type) {
+    case string:
+        size += len(v)
+    case []byte:
+        size += len(v)
+    }
+    return size
+}
+
+func (this *BoundedTrie) touch(entry *boundedEntry) {
+    this.tick++
+    entry.uses++
+    entry.last = this.tick
+    if this.policy == EvictLRU {
+        this.recent.MoveToFront(entry.element)
+    } else {
+        heap.Fix(&this.frequent, entry.index)
+    }
+}
+
+func (this *BoundedTrie) forget(entry *boundedEntry) {
+    delete(this.tracked, entry.key)
+    this.bytes -= entry.size
+    if this.policy == EvictLRU {
+        this.recent.Remove(entry.element)
+    } else {
+        heap.Remove(&this.frequent, entry.index)
+    }
+}
+
+func (this *BoundedTrie) overLimit() bool {
+    return (this.maxEntries > 0 && len(this.tracked) > this.maxEntries) ||
+        (this.maxBytes > 0 && this.bytes > this.maxBytes)
+}
+
+// AddEntry adds or replaces an entry, then evicts until the trie is back
+// within its limits. The entry just added is the last to go, it's only
+// evicted if it doesn't fit within the byte limit on its own.
+func (this *BoundedTrie) AddEntry(key string, value interface{}) {
+    if value == nil {
+        this.RemoveEntry(key)
+        return
+    }
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    this.entries.AddEntry(key, value)
+    entry := this.tracked[key]
+    if entry == nil {
+        entry = &boundedEntry {
+            key: key,
+        }
+        this.tracked[key] = entry
+        if this.policy == EvictLRU {
+            entry.element = this.recent.PushFront(entry)
+        } else {
+            heap.Push(&this.frequent, entry)
+        }
+    }
+    this.bytes += entrySize(key, value) - entry.size
+    entry.size = entrySize(key, value)
+    this.touch(entry)
+
+    for this.overLimit() {
+        var victim *boundedEntry
+        if this.policy == EvictLRU {
+            victim = this.recent.Back().Value.(*boundedEntry)
+        } else {
+            victim = this.frequent[0]
+            if victim == entry && len(this.frequent) > 1 {
+                // a new entry would always be the least used, so give it a
+                // chance and take the next one down instead
+                victim = this.frequent[1]
+                if len(this.frequent) > 2 && this.frequent.Less(2, 1) {
+                    victim = this.frequent[2]
+                }
+            }
+        }
+        this.forget(victim)
+        // RemoveEntry folds away the branches left empty
+        this.entries.RemoveEntry(victim.key)
+    }
+}
+
+// GetEntry works as it does for Trie, with a hit counting as a use
+func (this *BoundedTrie) GetEntry(key string) (value interface{}, validPath bool) {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    value, validPath = this.entries.GetEntry(key)
+    if value != nil {
+        this.touch(this.tracked[key])
+    }
+    return value, validPath
+}
+
+func (this *BoundedTrie) RemoveEntry(key string) (value interface{}, removed bool) {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    if entry := this.tracked[key]; entry != nil {
+        this.forget(entry)
+    }
+    return this.entries.RemoveEntry(key)
+}
+
+func (this *BoundedTrie) Len() int {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    return len(this.tracked)
+}
+
+// Bytes is the approximate size of the entries held
+func (this *BoundedTrie) Bytes() int {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    return this.bytes
+}
+
+// boundedHeap is a min heap on use count then recency
+type boundedHeap []*boundedEntry
+
+func (this boundedHeap) Len() int { return len(this) }
+func (this boundedHeap) Less(i, j int) bool {
+    if this[i].uses == this[j].uses {
+        return this[i].last < this[j].last
+    }
+    return this[i].uses < this[j].uses
+}
+func (this boundedHeap) Swap(i, j int) {
+    this[i], this[j] = this[j], this[i]
+    this[i].index = i
+    this[j].index = j
+}
+func (this *boundedHeap) Push(x interface{}) {
+    entry := x.(*boundedEntry)
+    entry.index = len(*this)
+    *this = append(*this, entry)
+}
+func (this *boundedHeap) Pop() interface{} {
+    old := *this
+    entry := old[len(old)-1]
+    *this = old[:len(old)-1]
+    return entry
+}
@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+
+func TestBoundedLRU(t *testing.T) {
+    trie := NewBoundedTrie(EvictLRU, 3, 0)
+    trie.AddEntry("http://a/1", 1)
+    trie.AddEntry("http://a/2", 2)
+    trie.AddEntry("http://b/1", 3)
+    trie.GetEntry("http://a/1")
+    trie.AddEntry("http://b/2", 4)
+
+    if value, _ := trie.GetEntry("http://a/2"); value != nil {
+        t.Errorf("Least recently used entry not evicted")
+    }
+    for _, key := range []string {"http://a/1", "http://b/1", "http://b/2"} {
+        if value, _ := trie.GetEntry(key); value == nil {
+            t.Errorf("Lost %s", key)
+        }
+    }
+    if trie.Len() != 3 {
+        t.Errorf("Expected 3 entries, got %d", trie.Len())
+    }
+    trie.AddEntry("http://c/1", 5)
+    if _, validPath := trie.GetEntry("http://a/"); validPath {
+        t.Errorf("Evicted branch not collapsed")
+    }
+}
+
+func TestBoundedLFU(t *testing.T) {
+    trie := NewBoundedTrie(EvictLFU, 3, 0)
+    trie.AddEntry("a", 1)
+    trie.AddEntry("b", 2)
+    trie.AddEntry("c", 3)
+    trie.GetEntry("a")
+    trie.GetEntry("a")
+    trie.GetEntry("c")
+    trie.AddEntry("d", 4)
+    if value, _ := trie.GetEntry("b"); value != nil {
+        t.Errorf("Least frequently used entry not evicted")
+    }
+    // c and d now have two uses each, c is the older of the two
+    trie.GetEntry("d")
+    trie.AddEntry("e", 5)
+    if value, _ := trie.GetEntry("c"); value != nil {
+        t.Errorf("Least recently used of the least frequent not evicted")
+    }
+    trie.RemoveEntry("a")
+    if trie.Len() != 2 {
+        t.Errorf("Expected 2 entries after removal, got %d", trie.Len())
+    }
+}
+
+func TestBoundedBytes(t *testing.T) {
+    trie := NewBoundedTrie(EvictLRU, 0, 10*(boundedEntryOverhead+8))
+    for x := 0; x < 100; x++ {
+        trie.AddEntry(fmt.Sprintf("KEY %04d", x), x)
+        if trie.Bytes() > 10*(boundedEntryOverhead+8) {
+            t.Errorf("Byte budget exceeded: %d", trie.Bytes())
+        }
+    }
+    if trie.Len() != 10 {
+        t.Errorf("Expected 10 entries within budget, got %d", trie.Len())
+    }
+    if value, _ := trie.GetEntry("KEY 0099"); value == nil {
+        t.Errorf("Lost the latest entry")
+    }
+}
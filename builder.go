@@ -0,0 +1,165 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "fmt"
+    "sync"
+)
+
+// Builder constructs a Trie from keys supplied in sorted order. Knowing the
+// order up front means every branch can be made once, with its final shortcut
+// and children, instead of being split over and over by AddToBranch.
+type Builder struct {
+    augment Augment
+    keys [][]byte
+    values []interface{}
+}
+
+func NewBuilder() *Builder {
+    return &Builder{}
+}
+
+// NewAugmentedBuilder builds a trie as NewAugmentedTrie would
+func NewAugmentedBuilder(augment Augment) *Builder {
+    return &Builder {
+        augment: augment,
+    }
+}
+
+// Add queues up an entry, keys must be strictly increasing in byte order
+func (this *Builder) Add(key string, value interface{}) error {
+    if value == nil {
+        return fmt.Errorf("nil value for key %q", key)
+    }
+    if n := len(this.keys); n > 0 && string(this.keys[n-1]) >= key {
+        return fmt.Errorf("key %q added after %q", key, this.keys[n-1])
+    }
+    this.keys = append(this.keys, []byte(key))
+    this.values = append(this.values, value)
+    return nil
+}
+
+func (this *Builder) newTrie() *Trie {
+    if this.augment != nil {
+        return NewAugmentedTrie(this.augment)
+    }
+    return NewTrie()
+}
+
+// Build returns the finished trie
+func (this *Builder) Build() *Trie {
+    trie := this.newTrie()
+    if len(this.keys) > 0 {
+        trie.tree = this.build(trie, 0, len(this.keys), 0)
+    }
+    return trie
+}
+
+// BuildParallel is Build with the subtrees below the first branching point
+// put together on up to workers goroutines at once
+func (this *Builder) BuildParallel(workers int) *Trie {
+    trie := this.newTrie()
+    if len(this.keys) == 0 {
+        return trie
+    }
+    if workers < 1 {
+        workers = 1
+    }
+    t, groups, depth := this.layout(trie, 0, len(this.keys), 0)
+    var wg sync.WaitGroup
+    sem := make(chan struct{}, workers)
+    for _, g := range groups {
+        wg.Add(1)
+        sem <- struct{}{}
+        go func(g buildGroup) {
+            defer wg.Done()
+            // each goroutine has its own slot in children
+            t.children[g.index] = this.build(trie, g.lo, g.hi, depth)
+            <-sem
+        }(g)
+    }
+    wg.Wait()
+    trie.refresh(t)
+    trie.tree = t
+    return trie
+}
+
+// buildGroup is a run of keys that all go to the same child
+type buildGroup struct {
+    index int
+    lo int
+    hi int
+}
+
+// build makes the branch for keys[lo:hi], which all share their first depth
+// bytes
+func (this *Builder) build(trie *Trie, lo int, hi int, depth int) *branch {
+    t, groups, next := this.layout(trie, lo, hi, depth)
+    for _, g := range groups {
+        t.children[g.index] = this.build(trie, g.lo, g.hi, next)
+    }
+    trie.refresh(t)
+    return t
+}
+
+// layout works out the shortcut and value for keys[lo:hi] and how the rest
+// of the keys divide between the children, returning the depth the children
+// start at
+func (this *Builder) layout(trie *Trie, lo int, hi int, depth int) (*branch, []buildGroup, int) {
+    first, last := this.keys[lo], this.keys[hi-1]
+    // sorted, so whatever the first and last share everything does
+    l := depth
+    for l < len(first) && l < len(last) && first[l] == last[l] {
+        l++
+    }
+    t := &branch {
+        shortcut: first[depth:l],
+    }
+    if len(first) == l {
+        t.value = this.values[lo]
+        lo++
+    }
+    groups := make([]buildGroup, 0)
+    size := 0
+    for x := lo; x < hi; {
+        b := this.keys[x][l]
+        y := x
+        for y < hi && this.keys[y][l] == b {
+            y++
+        }
+        index := trie.GetKey(b)
+        groups = append(groups, buildGroup{index, x, y})
+        if index+1 > size {
+            size = index+1
+        }
+        x = y
+    }
+    if size > 0 {
+        t.children = make([]*branch, size)
+    }
+    return t, groups, l+1
+}
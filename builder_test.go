@@ -0,0 +1,117 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+import "sort"
+
+func builderKeys() []string {
+    keys := make([]string, 0)
+    for x := 0; x < 500; x++ {
+        keys = append(keys, fmt.Sprintf("KEY %d", x*7))
+    }
+    keys = append(keys, orderedKeys...)
+    sort.Strings(keys)
+    return keys
+}
+
+func checkBuilt(t *testing.T, trie *Trie, keys []string) {
+    if trie.Len() != len(keys) {
+        t.Errorf("Expected %d entries, got %d", len(keys), trie.Len())
+    }
+    for x, key := range keys {
+        value, _ := trie.GetEntry(key)
+        if value == nil || value.(int) != x {
+            t.Errorf("Wrong value for %q", key)
+        }
+    }
+    x := 0
+    for key := range trie.All() {
+        if x >= len(keys) || keys[x] != key {
+            t.Errorf("Built trie out of order at %q", key)
+            break
+        }
+        x++
+    }
+    // must still be usable as a normal trie afterwards
+    trie.AddEntry("KEY 1", -1)
+    trie.RemoveEntry("KEY 0")
+    if value, _ := trie.GetEntry("KEY 1"); value.(int) != -1 {
+        t.Errorf("Unable to add to a built trie")
+    }
+    if value, _ := trie.GetEntry("KEY 0"); value != nil {
+        t.Errorf("Unable to remove from a built trie")
+    }
+}
+
+func TestBuilder(t *testing.T) {
+    keys := builderKeys()
+    builder := NewBuilder()
+    for x, key := range keys {
+        if err := builder.Add(key, x); err != nil {
+            t.Errorf("Unable to add %q: %s", key, err)
+        }
+    }
+    if err := builder.Add(keys[3], 0); err == nil {
+        t.Errorf("Out of order key not reported")
+    }
+    if err := builder.Add(keys[len(keys)-1], 0); err == nil {
+        t.Errorf("Duplicate key not reported")
+    }
+    checkBuilt(t, builder.Build(), keys)
+    checkBuilt(t, builder.BuildParallel(4), keys)
+
+    if NewBuilder().Build().Len() != 0 {
+        t.Errorf("Empty builder built entries")
+    }
+}
+
+func TestAugmentedBuilder(t *testing.T) {
+    builder := NewAugmentedBuilder(sumAugment{})
+    builder.Add("APPEAR", 3)
+    builder.Add("APPEARANCE", 5)
+    builder.Add("APPLE", 13)
+    trie := builder.BuildParallel(2)
+    if sum := trie.AggregatePrefix("APPE").(int); sum != 8 {
+        t.Errorf("Expected sum of 8, got %d", sum)
+    }
+}
+
+func BenchmarkBuilder(b *testing.B) {
+    b.StopTimer()
+    keys := make([]string, b.N)
+    for x := 0; x < b.N; x++ {
+        keys[x] = fmt.Sprintf("PHRASE %d", x)
+    }
+    sort.Strings(keys)
+    b.StartTimer()
+    builder := NewBuilder()
+    for x, key := range keys {
+        builder.Add(key, x)
+    }
+    builder.Build()
+}
@@ -0,0 +1,151 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "container/heap"
+    "strings"
+)
+
+// Counter keeps a count against each key, such as the number of times each
+// word or phrase appears in a body of text. Keys only exist while their count
+// is above zero.
+type Counter struct {
+    counts *Trie
+}
+
+// CountEntry is a key and its count
+type CountEntry struct {
+    Key string
+    Count int
+}
+
+func NewCounter() *Counter {
+    return &Counter {
+        counts: NewTrie(),
+    }
+}
+
+// Inc adds delta to the count for key and returns the new count. A count
+// that drops to zero or below removes the key, and comes back as zero just
+// as Get would return.
+func (this *Counter) Inc(key string, delta int) (count int) {
+    this.counts.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+        if exists {
+            count = old.(int)
+        }
+        count += delta
+        return count, count > 0
+    })
+    return max(count, 0)
+}
+
+// Get returns the count for key
+func (this *Counter) Get(key string) int {
+    value, _ := this.counts.GetEntry(key)
+    if value == nil {
+        return 0
+    }
+    return value.(int)
+}
+
+// Len is the number of keys being counted
+func (this *Counter) Len() int {
+    return this.counts.Len()
+}
+
+// IncTokens counts every run of 1 to maxN tokens in the stream, with the
+// tokens of each n-gram joined by a single space
+func (this *Counter) IncTokens(tokens []string, maxN int) {
+    for x := range tokens {
+        var gram strings.Builder
+        for n := 0; n < maxN && x+n < len(tokens); n++ {
+            if n > 0 {
+                gram.WriteByte(' ')
+            }
+            gram.WriteString(tokens[x+n])
+            this.Inc(gram.String(), 1)
+        }
+    }
+}
+
+// worse reports whether a should be dropped before b, with the later key
+// going first on a tie
+func worse(a CountEntry, b CountEntry) bool {
+    if a.Count == b.Count {
+        return a.Key > b.Key
+    }
+    return a.Count < b.Count
+}
+
+// countHeap is a min heap of the best entries found so far
+type countHeap []CountEntry
+
+func (this countHeap) Len() int { return len(this) }
+func (this countHeap) Less(i, j int) bool { return worse(this[i], this[j]) }
+func (this countHeap) Swap(i, j int) { this[i], this[j] = this[j], this[i] }
+func (this *countHeap) Push(x interface{}) { *this = append(*this, x.(CountEntry)) }
+func (this *countHeap) Pop() interface{} {
+    old := *this
+    entry := old[len(old)-1]
+    *this = old[:len(old)-1]
+    return entry
+}
+
+// MostCommon returns up to k of the keys starting with prefix with the
+// highest counts, highest first and in key order on a tie
+func (this *Counter) MostCommon(prefix string, k int) []CountEntry {
+    if k <= 0 {
+        return nil
+    }
+    best := make(countHeap, 0, k+1)
+    for key, value := range this.counts.EntriesWithPrefix(prefix) {
+        entry := CountEntry{key, value.(int)}
+        if len(best) == k && !worse(best[0], entry) {
+            continue
+        }
+        heap.Push(&best, entry)
+        if len(best) > k {
+            heap.Pop(&best)
+        }
+    }
+    result := make([]CountEntry, len(best))
+    for x := len(best)-1; x >= 0; x-- {
+        result[x] = heap.Pop(&best).(CountEntry)
+    }
+    return result
+}
+
+// Prune drops every key with a count below threshold, returning how many
+// were dropped
+func (this *Counter) Prune(threshold int) int {
+    defer this.counts.dispatch()
+    this.counts.lock.Lock()
+    defer this.counts.lock.Unlock()
+    return this.counts.removeWhere(func(value interface{}) bool {
+        return value.(int) < threshold
+    })
+}
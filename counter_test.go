@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+
+func TestCounterNGrams(t *testing.T) {
+    counter := NewCounter()
+    counter.IncTokens(Tokenize("the heat ray and the heat, the heat ray"), 3)
+
+    counts := map[string]int {
+        "the": 3,
+        "the heat": 3,
+        "the heat ray": 2,
+        "heat ray and": 1,
+        "ray and the": 1,
+        "and": 1,
+        "ray the": 0,
+    }
+    for key, expected := range counts {
+        if count := counter.Get(key); count != expected {
+            t.Errorf("Expected %d for %q, got %d", expected, key, count)
+        }
+    }
+
+    top := fmt.Sprint(counter.MostCommon("the", 2))
+    if top != "[{the 3} {the heat 3}]" {
+        t.Errorf("Unexpected most common: %s", top)
+    }
+    top = fmt.Sprint(counter.MostCommon("", 3))
+    if top != "[{heat 3} {the 3} {the heat 3}]" {
+        t.Errorf("Unexpected most common overall: %s", top)
+    }
+    if len(counter.MostCommon("zebra", 3)) != 0 {
+        t.Errorf("Found counts for a missing prefix")
+    }
+
+    if counter.Inc("the", -3) != 0 || counter.Get("the") != 0 {
+        t.Errorf("Count not dropped to zero")
+    }
+    if counter.Inc("martian", -1) != 0 || counter.Get("martian") != 0 {
+        t.Errorf("Count went below zero")
+    }
+    if counter.Get("the heat") != 3 {
+        t.Errorf("Dropping a key lost the keys below it")
+    }
+}
+
+func TestCounterPrune(t *testing.T) {
+    counter := NewCounter()
+    for x := 0; x < 100; x++ {
+        counter.Inc(fmt.Sprintf("WORD %d", x), x%5)
+    }
+    before := counter.Len()
+    removed := counter.Prune(3)
+    if removed != 40 || counter.Len() != before-40 {
+        t.Errorf("Expected to prune 40 of %d entries, pruned %d leaving %d", before, removed, counter.Len())
+    }
+    for x := 0; x < 100; x++ {
+        count := counter.Get(fmt.Sprintf("WORD %d", x))
+        if (x%5 >= 3 && count != x%5) || (x%5 < 3 && count != 0) {
+            t.Errorf("Wrong count for WORD %d after pruning: %d", x, count)
+        }
+    }
+    counter.Prune(10)
+    if counter.Len() != 0 {
+        t.Errorf("Expected nothing left, got %d", counter.Len())
+    }
+}
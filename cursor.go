@@ -0,0 +1,105 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+// Cursor remembers a position within a Trie, including how far through a
+// branch's shortcut it is, so a key can be matched a byte at a time without
+// going back to the root. A cursor is not safe to use while the trie is
+// being added to.
+type Cursor struct {
+    trie *Trie
+    t *branch
+    off int
+}
+
+// Root returns a cursor sitting at the empty key
+func (this *Trie) Root() *Cursor {
+    return &Cursor {
+        trie: this,
+        t: this.tree,
+        off: 0,
+    }
+}
+
+// Next moves the cursor along by b, returning false and leaving the cursor
+// where it was if no key continues that way
+func (this *Cursor) Next(b byte) bool {
+    t, off, ok := this.trie.step(this.t, this.off, b)
+    if !ok {
+        return false
+    }
+    this.t = t
+    this.off = off
+    return true
+}
+
+// NextString moves the cursor along by every byte of s, or not at all if the
+// whole of s can't be followed
+func (this *Cursor) NextString(s string) bool {
+    t, off := this.t, this.off
+    for x := 0; x < len(s); x++ {
+        var ok bool
+        t, off, ok = this.trie.step(t, off, s[x])
+        if !ok {
+            return false
+        }
+    }
+    this.t = t
+    this.off = off
+    return true
+}
+
+// IsKey reports whether an entry ends at the cursor
+func (this *Cursor) IsKey() bool {
+    return this.off == len(this.t.shortcut) && this.t.value != nil
+}
+
+// Value is the value of the entry ending at the cursor, or nil if there isn't
+// one
+func (this *Cursor) Value() interface{} {
+    if this.off != len(this.t.shortcut) {
+        return nil
+    }
+    return this.t.value
+}
+
+// HasChildren reports whether any longer key continues from the cursor
+func (this *Cursor) HasChildren() bool {
+    if this.off < len(this.t.shortcut) {
+        return true
+    }
+    for _, child := range this.t.children {
+        if child != nil {
+            return true
+        }
+    }
+    return false
+}
+
+func (this *Cursor) Clone() *Cursor {
+    c := *this
+    return &c
+}
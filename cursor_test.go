@@ -0,0 +1,74 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+
+func TestCursorSteps(t *testing.T) {
+    trie := NewTrie()
+    trie.AddEntry("shure", "1")
+    trie.AddEntry("shure asdf", "2")
+    trie.AddEntry("shura", "3")
+    trie.AddEntry("你好", "4")
+
+    c := trie.Root()
+    if c.IsKey() || !c.HasChildren() {
+        t.Errorf("Root cursor in the wrong state")
+    }
+    if !c.NextString("shu") {
+        t.Errorf("Failed to follow shu")
+    }
+    if c.IsKey() || c.Value() != nil {
+        t.Errorf("Value returned part way through a shortcut")
+    }
+    fork := c.Clone()
+    if !c.Next('r') || !c.Next('e') {
+        t.Errorf("Failed to step to shure")
+    }
+    if !c.IsKey() || c.Value().(string) != "1" {
+        t.Errorf("Unable to retrieve shure 1")
+    }
+    if c.NextString(" qwer") {
+        t.Errorf("Followed a path that doesn't exist")
+    }
+    if !c.NextString(" asdf") || c.Value().(string) != "2" {
+        t.Errorf("Cursor moved by a failed NextString")
+    }
+    if c.HasChildren() {
+        t.Errorf("Leaf reported children")
+    }
+    if !fork.NextString("ra") || fork.Value().(string) != "3" {
+        t.Errorf("Clone was moved along with the original")
+    }
+
+    c = trie.Root()
+    if !c.NextString("你") || c.IsKey() || !c.NextString("好") || c.Value().(string) != "4" {
+        t.Errorf("Unable to step through unicode entry")
+    }
+    if trie.Root().Next('x') {
+        t.Errorf("Stepped to a missing child")
+    }
+}
@@ -0,0 +1,138 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "iter"
+    "reflect"
+)
+
+type ChangeType int
+
+const (
+    ChangeAdded ChangeType = iota
+    ChangeRemoved
+    ChangeModified
+)
+
+func (this ChangeType) String() string {
+    switch this {
+    case ChangeAdded:
+        return "added"
+    case ChangeRemoved:
+        return "removed"
+    case ChangeModified:
+        return "modified"
+    }
+    return "unknown"
+}
+
+// Change is a single difference between two tries. OldValue is nil for an
+// added key and NewValue is nil for a removed one.
+type Change struct {
+    Type ChangeType
+    Key string
+    OldValue interface{}
+    NewValue interface{}
+}
+
+// Diff yields the changes needed to turn old into new, in key order. Any
+// branch shared by both tries is skipped without looking inside it, otherwise
+// values are compared with reflect.DeepEqual. The changes are all worked
+// out before the first is yielded, so the tries aren't locked while the loop
+// runs.
+func Diff(old *Trie, new *Trie) iter.Seq[Change] {
+    return func(yield func(Change) bool) {
+        var changes []Change
+        unlock := readLockBoth(old, new)
+        old.diff(old.tree, 0, new.tree, 0, nil, func(change Change) bool {
+            changes = append(changes, change)
+            return true
+        })
+        unlock()
+        for _, change := range changes {
+            if !yield(change) {
+                return
+            }
+        }
+    }
+}
+
+// diffAll reports every entry in the subtree found off bytes into t's
+// shortcut as added or removed. key is the path to that position.
+func (this *Trie) diffAll(t *branch, off int, key []byte, change ChangeType, yield func(Change) bool) bool {
+    prefix := append(make([]byte, 0, len(key)), key[:len(key)-off]...)
+    return this.ascend(t, prefix, nil, false, false, func(k string, v interface{}) bool {
+        if change == ChangeAdded {
+            return yield(Change{ChangeAdded, k, nil, v})
+        }
+        return yield(Change{ChangeRemoved, k, v, nil})
+    })
+}
+
+// diff walks the two subtrees in lockstep the same way merge does
+func (this *Trie) diff(a *branch, aoff int, b *branch, boff int, key []byte, yield func(Change) bool) bool {
+    switch {
+    case a == b && aoff == boff:
+        return true
+    case a == nil:
+        return this.diffAll(b, boff, key, ChangeAdded, yield)
+    case b == nil:
+        return this.diffAll(a, aoff, key, ChangeRemoved, yield)
+    }
+
+    sa := a.shortcut[aoff:]
+    sb := b.shortcut[boff:]
+    var l int
+    for l = 0; l < len(sa) && l < len(sb) && sa[l] == sb[l]; l++ {
+    }
+    key = append(key, sa[:l]...)
+    va, achildren, anext := this.splitAt(a, aoff+l)
+    vb, bchildren, bnext := this.splitAt(b, boff+l)
+
+    var change *Change
+    switch {
+    case va == nil && vb != nil:
+        change = &Change{ChangeAdded, string(key), nil, vb}
+    case va != nil && vb == nil:
+        change = &Change{ChangeRemoved, string(key), va, nil}
+    case va != nil && !reflect.DeepEqual(va, vb):
+        change = &Change{ChangeModified, string(key), va, vb}
+    }
+    if change != nil && !yield(*change) {
+        return false
+    }
+
+    n := childrenLen(achildren, anext, bchildren, bnext)
+    return byteOrder(n, false, func(y int) bool {
+        ca, caoff := childAt(a, aoff+l, achildren, anext, y)
+        cb, cboff := childAt(b, boff+l, bchildren, bnext, y)
+        if ca == nil && cb == nil {
+            return true
+        }
+        return this.diff(ca, caoff, cb, cboff, append(key, keyByte(y)), yield)
+    })
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "math/rand"
+import "sort"
+
+func TestDiff(t *testing.T) {
+    old := NewTrie()
+    old.AddEntry("shure", "1")
+    old.AddEntry("shure asdf", "2")
+    old.AddEntry("shura no toki", "3")
+    old.AddEntry("ebay", "4")
+    old.AddEntry("", "5")
+    new := NewTrie()
+    new.AddEntry("shure", "1")
+    new.AddEntry("shure asdf", "changed")
+    new.AddEntry("shurtrax max-pax", "6")
+    new.AddEntry("ebays", "7")
+    new.AddEntry("ebay", "4")
+    new.AddEntry("[", "8")
+
+    found := ""
+    for change := range Diff(old, new) {
+        found += change.Type.String() + ":" + change.Key + " "
+    }
+    expected := "removed: added:[ added:ebays removed:shura no toki modified:shure asdf added:shurtrax max-pax "
+    if found != expected {
+        t.Errorf("Unexpected changes: %s", found)
+    }
+    for change := range Diff(old, old) {
+        t.Errorf("Unexpected change diffing a trie against itself: %v", change)
+    }
+}
+
+func TestDiffRandom(t *testing.T) {
+    r := rand.New(rand.NewSource(2))
+    for round := 0; round < 50; round++ {
+        a, ea := randomSet(r, r.Intn(30), "a")
+        b, eb := randomSet(r, r.Intn(30), "b")
+        // share some keys and values between the two
+        for key := range ea {
+            if r.Intn(2) == 0 {
+                b.AddEntry(key, "a")
+                eb[key] = "a"
+            }
+        }
+        expected := make([]string, 0)
+        for key, value := range ea {
+            if other, exists := eb[key]; !exists {
+                expected = append(expected, key+"-")
+            } else if other != value {
+                expected = append(expected, key+"*")
+            }
+        }
+        for key := range eb {
+            if _, exists := ea[key]; !exists {
+                expected = append(expected, key+"+")
+            }
+        }
+        // sort on the key alone, without the marker on the end
+        sort.Slice(expected, func(i, j int) bool {
+            return expected[i][:len(expected[i])-1] < expected[j][:len(expected[j])-1]
+        })
+        found := make([]string, 0)
+        for change := range Diff(a, b) {
+            found = append(found, change.Key+map[ChangeType]string{ChangeAdded: "+", ChangeRemoved: "-", ChangeModified: "*"}[change.Type])
+        }
+        if len(found) != len(expected) {
+            t.Errorf("Round %d: expected %q, got %q", round, expected, found)
+            continue
+        }
+        for x := range found {
+            if found[x] != expected[x] {
+                t.Errorf("Round %d: expected %q, got %q", round, expected, found)
+                break
+            }
+        }
+    }
+}
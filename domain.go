@@ -0,0 +1,225 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "fmt"
+    "strings"
+    "unicode/utf8"
+)
+
+// DomainTrie holds domain rules keyed by their labels in reverse, so
+// "www.example.com" is stored as "com.example.www" and every parent domain of
+// a host is a prefix of it. A plain rule matches the domain and everything
+// below it, a rule like "*.example.com" only matches the subdomains.
+// Internationalised names must be in Unicode NFC, see normaliseDomain.
+type DomainTrie struct {
+    rules *Trie
+}
+
+type domainRule struct {
+    rule string
+    value interface{}
+}
+
+func NewDomainTrie() *DomainTrie {
+    return &DomainTrie {
+        rules: NewTrie(),
+    }
+}
+
+// normaliseDomain lower cases a domain and converts any internationalised
+// labels to punycode, returning the labels in order. That is only part of
+// IDNA: there is no Unicode normalisation or mapping, which would need
+// tables this package doesn't carry. Names are expected in NFC, as browsers
+// and DNS tools send them, and one written with decomposed characters, say
+// "mu\u0308nchen" instead of "m\u00fcnchen", gets different punycode and
+// won't match.
+func normaliseDomain(name string) ([]string, error) {
+    name = strings.TrimSuffix(strings.ToLower(name), ".")
+    if name == "" {
+        return nil, fmt.Errorf("empty domain")
+    }
+    labels := strings.Split(name, ".")
+    for x, label := range labels {
+        if label == "" {
+            return nil, fmt.Errorf("domain %s has an empty label", name)
+        }
+        if !utf8.ValidString(label) {
+            return nil, fmt.Errorf("domain %s is not valid UTF-8", name)
+        }
+        for y := 0; y < len(label); y++ {
+            if label[y] >= utf8.RuneSelf {
+                labels[x] = "xn--" + punycode(label)
+                break
+            }
+        }
+    }
+    return labels, nil
+}
+
+func reverseLabels(labels []string) string {
+    reversed := make([]string, len(labels))
+    for x, label := range labels {
+        reversed[len(labels)-1-x] = label
+    }
+    return strings.Join(reversed, ".")
+}
+
+// AddRule stores a domain, optionally starting with a "*." wildcard label
+func (this *DomainTrie) AddRule(rule string, value interface{}) error {
+    labels, err := normaliseDomain(rule)
+    if err != nil {
+        return err
+    }
+    for x, label := range labels {
+        if strings.Contains(label, "*") && (x > 0 || label != "*" || len(labels) == 1) {
+            return fmt.Errorf("rule %s: wildcards must be a whole leading label", rule)
+        }
+    }
+    this.rules.AddEntry(reverseLabels(labels), &domainRule {
+        rule: strings.Join(labels, "."),
+        value: value,
+    })
+    return nil
+}
+
+// MatchHost finds the most specific rule covering host in a single walk down
+// the tree. Rules with more labels are more specific, with a wildcard
+// counting as a label.
+func (this *DomainTrie) MatchHost(host string) (matchedRule string, v interface{}, ok bool) {
+    labels, err := normaliseDomain(host)
+    if err != nil {
+        return "", nil, false
+    }
+    this.rules.lock.RLock()
+    defer this.rules.lock.RUnlock()
+    var best *domainRule
+    t, off := this.rules.tree, 0
+    for x := len(labels)-1; x >= 0; x-- {
+        key := labels[x]
+        if x < len(labels)-1 {
+            key = "." + key
+        }
+        for y := 0; y < len(key) && t != nil; y++ {
+            t, off, ok = this.rules.step(t, off, key[y])
+        }
+        if !ok || t == nil {
+            break
+        }
+        if off == len(t.shortcut) && t.value != nil {
+            best = t.value.(*domainRule)
+        }
+        if x > 0 {
+            if dt, doff, ok := this.rules.step(t, off, '.'); ok {
+                if wt, woff, ok := this.rules.step(dt, doff, '*'); ok && woff == len(wt.shortcut) && wt.value != nil {
+                    best = wt.value.(*domainRule)
+                }
+            }
+        }
+    }
+    if best == nil {
+        return "", nil, false
+    }
+    return best.rule, best.value, true
+}
+
+// punycode encodes a label as per RFC 3492, without the "xn--" prefix
+func punycode(label string) string {
+    const base, tmin, tmax, skew, damp = 36, 1, 26, 38, 700
+    input := []rune(label)
+    output := make([]byte, 0, len(label)*2)
+    for _, r := range input {
+        if r < 0x80 {
+            output = append(output, byte(r))
+        }
+    }
+    b := len(output)
+    if b > 0 {
+        output = append(output, '-')
+    }
+    digit := func(d int) byte {
+        if d < 26 {
+            return byte('a' + d)
+        }
+        return byte('0' + d - 26)
+    }
+    adapt := func(delta, numPoints int, first bool) int {
+        if first {
+            delta /= damp
+        } else {
+            delta /= 2
+        }
+        delta += delta / numPoints
+        k := 0
+        for delta > ((base-tmin)*tmax)/2 {
+            delta /= base - tmin
+            k += base
+        }
+        return k + (base-tmin+1)*delta/(delta+skew)
+    }
+
+    n, delta, bias := 0x80, 0, 72
+    for h := b; h < len(input); {
+        m := int(utf8.MaxRune) + 1
+        for _, r := range input {
+            if int(r) >= n && int(r) < m {
+                m = int(r)
+            }
+        }
+        delta += (m - n) * (h + 1)
+        n = m
+        for _, r := range input {
+            if int(r) < n {
+                delta++
+            }
+            if int(r) != n {
+                continue
+            }
+            q := delta
+            for k := base; ; k += base {
+                t := k - bias
+                if t < tmin {
+                    t = tmin
+                } else if t > tmax {
+                    t = tmax
+                }
+                if q < t {
+                    break
+                }
+                output = append(output, digit(t+(q-t)%(base-t)))
+                q = (q - t) / (base - t)
+            }
+            output = append(output, digit(q))
+            bias = adapt(delta, h+1, h == b)
+            delta = 0
+            h++
+        }
+        delta++
+        n++
+    }
+    return string(output)
+}
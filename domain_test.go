@@ -0,0 +1,82 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+
+func TestDomainMatchHost(t *testing.T) {
+    domains := NewDomainTrie()
+    rules := []string {"example.com", "*.ads.example.com", "tracker.net", "*.cdn.io", "münchen.de"}
+    for _, rule := range rules {
+        if err := domains.AddRule(rule, rule); err != nil {
+            t.Errorf("Unable to add %s: %s", rule, err)
+        }
+    }
+
+    hosts := map[string]string {
+        "example.com": "example.com",
+        "www.example.com": "example.com",
+        "ads.example.com": "example.com",
+        "x.ads.example.com": "*.ads.example.com",
+        "WWW.Tracker.NET.": "tracker.net",
+        "img.cdn.io": "*.cdn.io",
+        "shop.münchen.de": "xn--mnchen-3ya.de",
+        "shop.xn--mnchen-3ya.de": "xn--mnchen-3ya.de",
+    }
+    for host, expected := range hosts {
+        rule, _, ok := domains.MatchHost(host)
+        if !ok || rule != expected {
+            t.Errorf("Host %s matched %s, expected %s", host, rule, expected)
+        }
+    }
+
+    for _, host := range []string {"cdn.io", "example.org", "notexample.com", "com", "a..com"} {
+        if rule, _, ok := domains.MatchHost(host); ok {
+            t.Errorf("Host %s unexpectedly matched %s", host, rule)
+        }
+    }
+
+    _, val, _ := domains.MatchHost("a.b.ads.example.com")
+    if val.(string) != "*.ads.example.com" {
+        t.Errorf("Wrong value for wildcard match")
+    }
+    if err := domains.AddRule("www.*.example.com", "x"); err == nil {
+        t.Errorf("Wildcard in the middle of a rule not rejected")
+    }
+}
+
+func TestPunycode(t *testing.T) {
+    labels := map[string]string {
+        "bücher": "bcher-kva",
+        "münchen": "mnchen-3ya",
+        "例え": "r8jz45g",
+    }
+    for label, expected := range labels {
+        if encoded := punycode(label); encoded != expected {
+            t.Errorf("Punycode of %s gave %s, expected %s", label, encoded, expected)
+        }
+    }
+}
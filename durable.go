@@ -0,0 +1,355 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "iter"
+    "os"
+    "path/filepath"
+    "sync"
+)
+
+const (
+    snapshotFile = "snapshot"
+    logFile = "log"
+    logHeaderLen = 8 // payload length then its checksum, both uint32
+    // anything longer is taken to be a corrupt header rather than trusted
+    // with an allocation
+    maxRecordLen = 16 << 20
+)
+
+const (
+    opPut byte = iota
+    opRemove
+)
+
+// DurableOptions tunes a DurableTrie, the zero value syncs every write and
+// never compacts on its own
+type DurableOptions struct {
+    // NoSync skips the fsync after each write, trading the last few writes
+    // on a crash for speed
+    NoSync bool
+    // CompactBytes compacts the log into a new snapshot once it grows past
+    // this size. A write that sets off a compaction succeeds whether or not
+    // the compaction does, a failed one is tried again on the next write and
+    // the error is kept for CompactErr.
+    CompactBytes int64
+}
+
+// DurableTrie is a Trie backed by a directory holding a snapshot and an
+// append only log of every change since. Opening it loads the snapshot and
+// replays the log, dropping any torn record at the end of it. Values are
+// stored with gob, so anything other than the basic types needs to be passed
+// to gob.Register.
+type DurableTrie struct {
+    entries *Trie
+    dir string
+    options DurableOptions
+    log *os.File
+    logSize int64
+    compactErr error // from the last automatic compaction
+    lock sync.Mutex // serialises writes to the log
+}
+
+type logRecord struct {
+    Op byte
+    Key string
+    Value interface{}
+}
+
+type snapshotEntry struct {
+    Key string
+    Value interface{}
+}
+
+// OpenDurableTrie opens or creates the trie stored in dir
+func OpenDurableTrie(dir string, options DurableOptions) (*DurableTrie, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, err
+    }
+    this := &DurableTrie {
+        entries: NewTrie(),
+        dir: dir,
+        options: options,
+    }
+    if err := this.loadSnapshot(); err != nil {
+        return nil, err
+    }
+    log, err := os.OpenFile(filepath.Join(dir, logFile), os.O_RDWR|os.O_CREATE, 0644)
+    if err != nil {
+        return nil, err
+    }
+    this.log = log
+    if err = this.replay(); err != nil {
+        log.Close()
+        return nil, err
+    }
+    return this, nil
+}
+
+func (this *DurableTrie) loadSnapshot() error {
+    f, err := os.Open(filepath.Join(this.dir, snapshotFile))
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    dec := gob.NewDecoder(bufio.NewReader(f))
+    for {
+        var entry snapshotEntry
+        err := dec.Decode(&entry)
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("reading snapshot: %w", err)
+        }
+        this.entries.AddEntry(entry.Key, entry.Value)
+    }
+}
+
+// replay applies every intact record in the log, then cuts the log off
+// after the last of them so new records follow on from good data
+func (this *DurableTrie) replay() error {
+    r := bufio.NewReader(this.log)
+    var good int64
+    header := make([]byte, logHeaderLen)
+    for {
+        if _, err := io.ReadFull(r, header); err != nil {
+            break
+        }
+        length := binary.LittleEndian.Uint32(header[0:4])
+        checksum := binary.LittleEndian.Uint32(header[4:8])
+        if length > maxRecordLen {
+            break
+        }
+        payload := make([]byte, length)
+        if _, err := io.ReadFull(r, payload); err != nil {
+            break
+        }
+        if crc32.ChecksumIEEE(payload) != checksum {
+            break
+        }
+        var record logRecord
+        if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&record); err != nil {
+            break
+        }
+        this.apply(record)
+        good += int64(logHeaderLen) + int64(length)
+    }
+    if err := this.log.Truncate(good); err != nil {
+        return err
+    }
+    this.logSize = good
+    _, err := this.log.Seek(good, io.SeekStart)
+    return err
+}
+
+func (this *DurableTrie) apply(record logRecord) {
+    switch record.Op {
+    case opPut:
+        this.entries.AddEntry(record.Key, record.Value)
+    case opRemove:
+        this.entries.RemoveEntry(record.Key)
+    }
+}
+
+// append writes a record to the end of the log, applying it once it is safe
+func (this *DurableTrie) append(record logRecord) error {
+    if this.log == nil {
+        return errors.New("durable trie is closed")
+    }
+    var payload bytes.Buffer
+    if err := gob.NewEncoder(&payload).Encode(record); err != nil {
+        return err
+    }
+    if payload.Len() > maxRecordLen {
+        return fmt.Errorf("durable trie record of %d bytes is over the limit of %d", payload.Len(), maxRecordLen)
+    }
+    buf := make([]byte, logHeaderLen, logHeaderLen+payload.Len())
+    binary.LittleEndian.PutUint32(buf[0:4], uint32(payload.Len()))
+    binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+    buf = append(buf, payload.Bytes()...)
+    if _, err := this.log.Write(buf); err != nil {
+        // don't leave half a record for the next one to follow
+        this.rollback()
+        return err
+    }
+    if !this.options.NoSync {
+        if err := this.log.Sync(); err != nil {
+            // the record may be in the file, and as far as the caller knows
+            // it failed, so it mustn't be replayed later
+            this.rollback()
+            return err
+        }
+    }
+    this.logSize += int64(len(buf))
+    this.apply(record)
+    if this.options.CompactBytes > 0 && this.logSize >= this.options.CompactBytes {
+        // the record is safe either way, so this isn't the caller's error
+        this.compactErr = this.compact()
+    }
+    return nil
+}
+
+// rollback cuts the log back to the last record that was applied
+func (this *DurableTrie) rollback() {
+    this.log.Truncate(this.logSize)
+    this.log.Seek(this.logSize, io.SeekStart)
+}
+
+func (this *DurableTrie) AddEntry(key string, value interface{}) error {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    if value == nil {
+        return this.append(logRecord{Op: opRemove, Key: key})
+    }
+    return this.append(logRecord{Op: opPut, Key: key, Value: value})
+}
+
+func (this *DurableTrie) RemoveEntry(key string) (value interface{}, removed bool, err error) {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    value, _ = this.entries.GetEntry(key)
+    if value == nil {
+        return nil, false, nil
+    }
+    if err = this.append(logRecord{Op: opRemove, Key: key}); err != nil {
+        return nil, false, err
+    }
+    return value, true, nil
+}
+
+func (this *DurableTrie) GetEntry(key string) (value interface{}, validPath bool) {
+    return this.entries.GetEntry(key)
+}
+
+func (this *DurableTrie) All() iter.Seq2[string, interface{}] {
+    return this.entries.All()
+}
+
+func (this *DurableTrie) EntriesWithPrefix(prefix string) iter.Seq2[string, interface{}] {
+    return this.entries.EntriesWithPrefix(prefix)
+}
+
+func (this *DurableTrie) Len() int {
+    return this.entries.Len()
+}
+
+// Compact writes the current entries out as a new snapshot and empties the
+// log
+func (this *DurableTrie) Compact() error {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    if this.log == nil {
+        return errors.New("durable trie is closed")
+    }
+    return this.compact()
+}
+
+// CompactErr returns the error from the last automatic compaction, or nil if
+// it succeeded
+func (this *DurableTrie) CompactErr() error {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    return this.compactErr
+}
+
+func (this *DurableTrie) compact() error {
+    tmp := filepath.Join(this.dir, snapshotFile+".tmp")
+    f, err := os.Create(tmp)
+    if err != nil {
+        return err
+    }
+    w := bufio.NewWriter(f)
+    enc := gob.NewEncoder(w)
+    for key, value := range this.entries.All() {
+        if err == nil {
+            err = enc.Encode(snapshotEntry{key, value})
+        }
+    }
+    if err == nil {
+        err = w.Flush()
+    }
+    if err == nil {
+        err = f.Sync()
+    }
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err != nil {
+        os.Remove(tmp)
+        return err
+    }
+    // the rename is the commit point, a crash before it leaves the old
+    // snapshot and the full log, a crash after it replays a log that's
+    // already in the snapshot, which comes to the same thing
+    if err = os.Rename(tmp, filepath.Join(this.dir, snapshotFile)); err != nil {
+        return err
+    }
+    if err = syncDir(this.dir); err != nil {
+        return err
+    }
+    if err = this.log.Truncate(0); err != nil {
+        return err
+    }
+    if _, err = this.log.Seek(0, io.SeekStart); err != nil {
+        return err
+    }
+    this.logSize = 0
+    return this.log.Sync()
+}
+
+func syncDir(dir string) error {
+    d, err := os.Open(dir)
+    if err != nil {
+        return err
+    }
+    defer d.Close()
+    return d.Sync()
+}
+
+// Close closes the log, the trie can't be written to afterwards
+func (this *DurableTrie) Close() error {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    if this.log == nil {
+        return nil
+    }
+    err := this.log.Close()
+    this.log = nil
+    return err
+}
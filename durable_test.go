@@ -0,0 +1,161 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "os"
+import "path/filepath"
+import "fmt"
+
+func openDurable(t *testing.T, dir string, options DurableOptions) *DurableTrie {
+    trie, err := OpenDurableTrie(dir, options)
+    if err != nil {
+        t.Fatalf("Unable to open durable trie: %s", err)
+    }
+    return trie
+}
+
+func TestDurableReopen(t *testing.T) {
+    dir := t.TempDir()
+    trie := openDurable(t, dir, DurableOptions{NoSync: true})
+    for x := 0; x < 50; x++ {
+        if err := trie.AddEntry(fmt.Sprintf("KEY %d", x), x); err != nil {
+            t.Errorf("Unable to add entry: %s", err)
+        }
+    }
+    trie.RemoveEntry("KEY 7")
+    if err := trie.Compact(); err != nil {
+        t.Errorf("Unable to compact: %s", err)
+    }
+    trie.AddEntry("KEY 8", "replaced")
+    trie.RemoveEntry("KEY 9")
+    trie.AddEntry("KEY 50", 50)
+    trie.Close()
+    if err := trie.AddEntry("KEY 51", 51); err == nil {
+        t.Errorf("Wrote to a closed trie")
+    }
+
+    trie = openDurable(t, dir, DurableOptions{})
+    defer trie.Close()
+    if trie.Len() != 49 {
+        t.Errorf("Expected 49 entries after reopening, got %d", trie.Len())
+    }
+    if value, _ := trie.GetEntry("KEY 8"); value.(string) != "replaced" {
+        t.Errorf("Lost the update made after compacting")
+    }
+    for _, key := range []string {"KEY 7", "KEY 9"} {
+        if value, _ := trie.GetEntry(key); value != nil {
+            t.Errorf("Removed entry %s came back", key)
+        }
+    }
+    if value, _ := trie.GetEntry("KEY 49"); value.(int) != 49 {
+        t.Errorf("Lost an entry from the snapshot")
+    }
+}
+
+func TestDurableTornLog(t *testing.T) {
+    dir := t.TempDir()
+    trie := openDurable(t, dir, DurableOptions{})
+    trie.AddEntry("shure", "1")
+    trie.AddEntry("shure asdf", "2")
+    trie.AddEntry("shura", "3")
+    trie.Close()
+
+    // chop the last record in half, as a crash part way through a write would
+    logPath := filepath.Join(dir, logFile)
+    info, _ := os.Stat(logPath)
+    if err := os.Truncate(logPath, info.Size()-5); err != nil {
+        t.Fatalf("Unable to truncate log: %s", err)
+    }
+    trie = openDurable(t, dir, DurableOptions{})
+    if trie.Len() != 2 {
+        t.Errorf("Expected 2 entries to survive, got %d", trie.Len())
+    }
+    if value, _ := trie.GetEntry("shura"); value != nil {
+        t.Errorf("Torn record was applied")
+    }
+    // writing again must carry on from the last good record
+    trie.AddEntry("shura", "4")
+    if err := trie.AddEntry("huge", string(make([]byte, maxRecordLen))); err == nil {
+        t.Errorf("Wrote a record too long to be read back")
+    }
+    trie.Close()
+
+    f, _ := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0644)
+    f.Write([]byte {1, 2, 3, 4, 5, 6, 7, 8, 9})
+    f.Close()
+    trie = openDurable(t, dir, DurableOptions{})
+    defer trie.Close()
+    if value, _ := trie.GetEntry("shura"); value == nil || value.(string) != "4" {
+        t.Errorf("Lost the record written after recovery")
+    }
+    if trie.Len() != 3 {
+        t.Errorf("Expected 3 entries, got %d", trie.Len())
+    }
+}
+
+func TestDurableAutoCompact(t *testing.T) {
+    dir := t.TempDir()
+    trie := openDurable(t, dir, DurableOptions{NoSync: true, CompactBytes: 1024})
+    for x := 0; x < 100; x++ {
+        trie.AddEntry(fmt.Sprintf("KEY %d", x%10), x)
+    }
+    trie.Close()
+    info, _ := os.Stat(filepath.Join(dir, logFile))
+    if info.Size() >= 1024 {
+        t.Errorf("Log not compacted, %d bytes", info.Size())
+    }
+    trie = openDurable(t, dir, DurableOptions{})
+    defer trie.Close()
+    if value, _ := trie.GetEntry("KEY 9"); value.(int) != 99 {
+        t.Errorf("Wrong value after compaction: %v", value)
+    }
+}
+
+func TestDurableCompactFailure(t *testing.T) {
+    dir := t.TempDir()
+    trie := openDurable(t, dir, DurableOptions{NoSync: true, CompactBytes: 256})
+    defer trie.Close()
+    // a directory where the new snapshot goes stops it being written
+    blocker := filepath.Join(dir, snapshotFile+".tmp")
+    os.Mkdir(blocker, 0755)
+    for x := 0; x < 20; x++ {
+        if err := trie.AddEntry(fmt.Sprintf("KEY %d", x), x); err != nil {
+            t.Errorf("Write failed because compaction did: %s", err)
+        }
+    }
+    if trie.CompactErr() == nil {
+        t.Errorf("Expected the compaction error to be kept")
+    }
+    os.Remove(blocker)
+    trie.AddEntry("KEY 20", 20)
+    if err := trie.CompactErr(); err != nil {
+        t.Errorf("Compaction wasn't retried: %s", err)
+    }
+    if trie.Len() != 21 {
+        t.Errorf("Expected 21 entries, got %d", trie.Len())
+    }
+}
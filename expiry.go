@@ -0,0 +1,170 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "iter"
+    "sync"
+    "time"
+)
+
+// ExpiringTrie is a Trie where entries can be given a time to live. Expired
+// entries are hidden from reads straight away and removed later, either when
+// a read comes across them, by Sweep, or by a janitor goroutine.
+type ExpiringTrie struct {
+    entries *Trie
+    clock func() time.Time
+}
+
+type expiringValue struct {
+    value interface{}
+    expires time.Time // zero for never
+}
+
+// NewExpiringTrie returns an empty trie using clock to tell the time, or
+// time.Now if clock is nil
+func NewExpiringTrie(clock func() time.Time) *ExpiringTrie {
+    if clock == nil {
+        clock = time.Now
+    }
+    return &ExpiringTrie {
+        entries: NewTrie(),
+        clock: clock,
+    }
+}
+
+func (this *ExpiringTrie) expired(v *expiringValue, now time.Time) bool {
+    return !v.expires.IsZero() && !now.Before(v.expires)
+}
+
+// AddEntry adds an entry that never expires. As with Trie, a nil value
+// removes the entry instead.
+func (this *ExpiringTrie) AddEntry(key string, value interface{}) {
+    if value == nil {
+        this.entries.RemoveEntry(key)
+        return
+    }
+    this.entries.AddEntry(key, &expiringValue{value: value})
+}
+
+// AddEntryWithTTL adds an entry that expires once ttl has passed, or removes
+// it if value is nil
+func (this *ExpiringTrie) AddEntryWithTTL(key string, value interface{}, ttl time.Duration) {
+    if value == nil {
+        this.entries.RemoveEntry(key)
+        return
+    }
+    this.entries.AddEntry(key, &expiringValue {
+        value: value,
+        expires: this.clock().Add(ttl),
+    })
+}
+
+// GetEntry works as it does for Trie, but never returns an expired value.
+// validPath may still count expired entries that haven't been swept up.
+func (this *ExpiringTrie) GetEntry(key string) (value interface{}, validPath bool) {
+    found, validPath := this.entries.GetEntry(key)
+    if found == nil {
+        return nil, validPath
+    }
+    v := found.(*expiringValue)
+    if this.expired(v, this.clock()) {
+        // only remove it if it hasn't been replaced in the meantime
+        this.entries.CompareAndSwap(key, v, nil)
+        _, validPath = this.entries.GetEntry(key)
+        return nil, validPath
+    }
+    return v.value, true
+}
+
+func (this *ExpiringTrie) RemoveEntry(key string) (value interface{}, removed bool) {
+    found, removed := this.entries.RemoveEntry(key)
+    if !removed || this.expired(found.(*expiringValue), this.clock()) {
+        return nil, false
+    }
+    return found.(*expiringValue).value, true
+}
+
+// live drops the expired entries from a walk over the tree
+func (this *ExpiringTrie) live(entries iter.Seq2[string, interface{}]) iter.Seq2[string, interface{}] {
+    return func(yield func(string, interface{}) bool) {
+        now := this.clock()
+        for key, found := range entries {
+            v := found.(*expiringValue)
+            if !this.expired(v, now) && !yield(key, v.value) {
+                return
+            }
+        }
+    }
+}
+
+// All yields every unexpired entry in key order
+func (this *ExpiringTrie) All() iter.Seq2[string, interface{}] {
+    return this.live(this.entries.All())
+}
+
+// EntriesWithPrefix yields every unexpired entry starting with prefix
+func (this *ExpiringTrie) EntriesWithPrefix(prefix string) iter.Seq2[string, interface{}] {
+    return this.live(this.entries.EntriesWithPrefix(prefix))
+}
+
+// Sweep removes every expired entry, returning how many there were
+func (this *ExpiringTrie) Sweep() int {
+    now := this.clock()
+    defer this.entries.dispatch()
+    this.entries.lock.Lock()
+    defer this.entries.lock.Unlock()
+    return this.entries.removeWhere(func(value interface{}) bool {
+        return this.expired(value.(*expiringValue), now)
+    })
+}
+
+// StartJanitor sweeps every interval on its own goroutine until the returned
+// function is called, which can safely be called more than once
+func (this *ExpiringTrie) StartJanitor(interval time.Duration) (stop func()) {
+    done := make(chan struct{})
+    stopped := make(chan struct{})
+    go func() {
+        defer close(stopped)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                this.Sweep()
+            case <-done:
+                return
+            }
+        }
+    }()
+    var once sync.Once
+    return func() {
+        once.Do(func() {
+            close(done)
+        })
+        <-stopped
+    }
+}
@@ -0,0 +1,105 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "time"
+
+type fakeClock struct {
+    now time.Time
+}
+
+func (this *fakeClock) Now() time.Time {
+    return this.now
+}
+
+func TestExpiry(t *testing.T) {
+    clock := &fakeClock{time.Unix(1000, 0)}
+    trie := NewExpiringTrie(clock.Now)
+    trie.AddEntryWithTTL("session aaa", "1", time.Minute)
+    trie.AddEntryWithTTL("session aab", "2", time.Hour)
+    trie.AddEntry("session", "3")
+
+    if value, _ := trie.GetEntry("session aaa"); value.(string) != "1" {
+        t.Errorf("Entry expired early")
+    }
+    clock.now = clock.now.Add(time.Minute)
+    if value, validPath := trie.GetEntry("session aaa"); value != nil || validPath {
+        t.Errorf("Expired entry still visible")
+    }
+    if value, _ := trie.GetEntry("session aab"); value.(string) != "2" {
+        t.Errorf("Lost an unexpired entry")
+    }
+
+    trie.AddEntry("session zzz", "5")
+    trie.AddEntry("session zzz", nil)
+    if _, validPath := trie.GetEntry("session zzz"); validPath || trie.entries.Len() != 2 {
+        t.Errorf("Adding a nil value left an entry behind")
+    }
+
+    trie.AddEntryWithTTL("session abc", "4", time.Second)
+    clock.now = clock.now.Add(time.Second)
+    found := ""
+    for key := range trie.EntriesWithPrefix("session a") {
+        found += key + ","
+    }
+    if found != "session aab," {
+        t.Errorf("Iteration found expired entries: %s", found)
+    }
+    if _, removed := trie.RemoveEntry("session abc"); removed {
+        t.Errorf("Removed an expired entry")
+    }
+
+    trie.AddEntryWithTTL("session abd", "5", time.Second)
+    trie.AddEntryWithTTL("session abe", "6", time.Second)
+    clock.now = clock.now.Add(time.Hour)
+    if swept := trie.Sweep(); swept != 3 {
+        t.Errorf("Expected to sweep 3 entries, swept %d", swept)
+    }
+    if trie.entries.Len() != 1 {
+        t.Errorf("Expected 1 entry left, got %d", trie.entries.Len())
+    }
+    if value, _ := trie.GetEntry("session"); value.(string) != "3" {
+        t.Errorf("Swept an entry without a TTL")
+    }
+}
+
+func TestExpiryJanitor(t *testing.T) {
+    clock := &fakeClock{time.Unix(1000, 0)}
+    trie := NewExpiringTrie(clock.Now)
+    trie.AddEntryWithTTL("token", "1", time.Second)
+    clock.now = clock.now.Add(time.Second)
+    stop := trie.StartJanitor(time.Millisecond)
+    deadline := time.Now().Add(time.Second)
+    for trie.entries.Len() > 0 && time.Now().Before(deadline) {
+        time.Sleep(time.Millisecond)
+    }
+    stop()
+    stop()
+    if trie.entries.Len() != 0 {
+        t.Errorf("Janitor failed to sweep the expired entry")
+    }
+}
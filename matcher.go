@@ -0,0 +1,340 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "context"
+    "io"
+    "runtime"
+    "sort"
+    "sync"
+)
+
+// Match is one place a key turned up in some text. Start and End are byte
+// offsets, with End just past the last byte of the key.
+type Match struct {
+    Start int64
+    End int64
+    Key string
+    Value interface{}
+}
+
+// Matcher finds every key of a trie in a body of text in a single pass,
+// however many keys there are. It is an Aho-Corasick automaton compiled from
+// the trie, so later changes to the trie don't affect it, and it is never
+// changed once compiled so any number of goroutines can share one.
+type Matcher struct {
+    states []matchState
+    root [256]int32 // moves out of the root, worked out in full
+    patterns []matchPattern
+    longest int
+}
+
+type matchState struct {
+    edges []matchEdge // in byte order
+    fail int32 // the longest proper suffix of this state that is also a state
+    pattern int32 // the key ending here, or -1
+    output int32 // the nearest state down the fail links with a key, or -1
+    depth int32 // how many bytes it takes to get here from the root
+}
+
+type matchEdge struct {
+    b byte
+    to int32
+}
+
+type matchPattern struct {
+    key string
+    value interface{}
+}
+
+// Compile builds a Matcher for the entries in the trie. The empty key would
+// match everywhere, so it is left out.
+func (this *Trie) Compile() *Matcher {
+    m := &Matcher {
+        states: []matchState{{pattern: -1, output: -1}},
+    }
+    for key, value := range this.All() {
+        if key != "" {
+            m.add(key, value)
+        }
+    }
+    m.link()
+    return m
+}
+
+// Longest is the length of the longest key the matcher looks for
+func (this *Matcher) Longest() int {
+    return this.longest
+}
+
+func (this *Matcher) edge(s int32, b byte) int32 {
+    edges := this.states[s].edges
+    x := sort.Search(len(edges), func(x int) bool { return edges[x].b >= b })
+    if x < len(edges) && edges[x].b == b {
+        return edges[x].to
+    }
+    return -1
+}
+
+func (this *Matcher) add(key string, value interface{}) {
+    s := int32(0)
+    for x := 0; x < len(key); x++ {
+        next := this.edge(s, key[x])
+        if next < 0 {
+            next = int32(len(this.states))
+            this.states = append(this.states, matchState{pattern: -1, output: -1, depth: int32(x+1)})
+            edges := this.states[s].edges
+            y := sort.Search(len(edges), func(y int) bool { return edges[y].b >= key[x] })
+            edges = append(edges, matchEdge{})
+            copy(edges[y+1:], edges[y:])
+            edges[y] = matchEdge{key[x], next}
+            this.states[s].edges = edges
+        }
+        s = next
+    }
+    this.states[s].pattern = int32(len(this.patterns))
+    this.patterns = append(this.patterns, matchPattern{key, value})
+    if len(key) > this.longest {
+        this.longest = len(key)
+    }
+}
+
+// link fills in the fail and output links, breadth first so every shorter
+// state is done before the ones that depend on it
+func (this *Matcher) link() {
+    for _, e := range this.states[0].edges {
+        this.root[e.b] = e.to
+    }
+    queue := make([]int32, 0, len(this.states))
+    for _, e := range this.states[0].edges {
+        queue = append(queue, e.to)
+    }
+    for len(queue) > 0 {
+        s := queue[0]
+        queue = queue[1:]
+        for _, e := range this.states[s].edges {
+            fail := this.next(this.states[s].fail, e.b)
+            this.states[e.to].fail = fail
+            if this.states[fail].pattern >= 0 {
+                this.states[e.to].output = fail
+            } else {
+                this.states[e.to].output = this.states[fail].output
+            }
+            queue = append(queue, e.to)
+        }
+    }
+}
+
+// next is the state reached from s on reading b
+func (this *Matcher) next(s int32, b byte) int32 {
+    for s != 0 {
+        if to := this.edge(s, b); to >= 0 {
+            return to
+        }
+        s = this.states[s].fail
+    }
+    return this.root[b]
+}
+
+// scan runs text through the automaton from state s, calling fn with every
+// match as it ends. base is the offset of text in the whole input. It
+// returns the state it finished in, so a scan can carry on where another
+// left off, and stops early if fn returns false.
+func (this *Matcher) scan(text []byte, base int64, s int32, fn func(Match) bool) (int32, bool) {
+    for x := 0; x < len(text); x++ {
+        s = this.next(s, text[x])
+        for o := s; o >= 0; o = this.states[o].output {
+            p := this.states[o].pattern
+            if p < 0 {
+                continue
+            }
+            pattern := &this.patterns[p]
+            end := base + int64(x) + 1
+            if !fn(Match{end - int64(len(pattern.key)), end, pattern.key, pattern.value}) {
+                return s, false
+            }
+        }
+    }
+    return s, true
+}
+
+// sortMatches puts matches in offset order, shorter first where they start
+// together
+func sortMatches(matches []Match) {
+    sort.Slice(matches, func(x, y int) bool {
+        if matches[x].Start != matches[y].Start {
+            return matches[x].Start < matches[y].Start
+        }
+        return matches[x].End < matches[y].End
+    })
+}
+
+// FindAll returns every match in text, overlapping ones included, in offset
+// order
+func (this *Matcher) FindAll(text []byte) []Match {
+    var matches []Match
+    this.scan(text, 0, 0, func(m Match) bool {
+        matches = append(matches, m)
+        return true
+    })
+    sortMatches(matches)
+    return matches
+}
+
+// FindAllContext is FindAll, giving up with ctx.Err() if ctx is cancelled
+// part way through the text
+func (this *Matcher) FindAllContext(ctx context.Context, text []byte) ([]Match, error) {
+    var matches []Match
+    err := this.scanContext(ctx, text, 0, func(m Match) {
+        matches = append(matches, m)
+    })
+    if err != nil {
+        return nil, err
+    }
+    sortMatches(matches)
+    return matches, nil
+}
+
+// scanContext runs all of text through the automaton, a piece at a time,
+// looking at ctx between pieces
+func (this *Matcher) scanContext(ctx context.Context, text []byte, base int64, fn func(Match)) error {
+    s := int32(0)
+    for from := 0; from < len(text); from += scanCheck {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        to := min(from+scanCheck, len(text))
+        s, _ = this.scan(text[from:to], base+int64(from), s, func(m Match) bool {
+            fn(m)
+            return true
+        })
+    }
+    return nil
+}
+
+const minScanChunk = 64 << 10
+const maxScanChunk = 16 << 20
+
+// ScanParallel finds every match in the first size bytes of r, like FindAll,
+// with workers goroutines each scanning a chunk at a time, or GOMAXPROCS of
+// them if workers is 0 or less. Each chunk is read along with enough of the
+// next one to finish any match that starts in it, and a match only counts
+// for the chunk it starts in, so nothing is found twice. It stops with
+// ctx.Err() if ctx is cancelled, or with the first error reading r.
+func (this *Matcher) ScanParallel(ctx context.Context, r io.ReaderAt, size int64, workers int) ([]Match, error) {
+    if workers < 1 {
+        workers = runtime.GOMAXPROCS(0)
+    }
+    // a few chunks per worker keeps them all busy to the end
+    chunk := size / int64(workers*4)
+    if chunk < minScanChunk {
+        chunk = minScanChunk
+    }
+    if chunk > maxScanChunk {
+        chunk = maxScanChunk
+    }
+    return this.scanParallel(ctx, r, size, workers, chunk)
+}
+
+func (this *Matcher) scanParallel(ctx context.Context, r io.ReaderAt, size int64, workers int, chunk int64) ([]Match, error) {
+    chunks := (size + chunk - 1) / chunk
+    results := make([][]Match, chunks)
+    scanCtx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    var failed sync.Once
+    var err error
+    jobs := make(chan int64)
+    var wg sync.WaitGroup
+    for w := 0; w < workers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            buf := make([]byte, chunk+int64(this.longest))
+            for x := range jobs {
+                matches, chunkErr := this.scanChunk(scanCtx, r, size, x*chunk, chunk, buf)
+                if chunkErr != nil {
+                    failed.Do(func() {
+                        err = chunkErr
+                        cancel()
+                    })
+                    continue
+                }
+                results[x] = matches
+            }
+        }()
+    }
+feed:
+    for x := int64(0); x < chunks; x++ {
+        select {
+        case jobs <- x:
+        case <-scanCtx.Done():
+            break feed
+        }
+    }
+    close(jobs)
+    wg.Wait()
+    if ctx.Err() != nil {
+        return nil, ctx.Err()
+    }
+    if err != nil {
+        return nil, err
+    }
+    var matches []Match
+    for _, found := range results {
+        matches = append(matches, found...)
+    }
+    return matches, nil
+}
+
+// scanCheck is how many bytes are scanned between looks at the context
+const scanCheck = 64 << 10
+
+// scanChunk finds the matches starting in the chunk at lo, reading it into
+// buf along with the overlap into the next chunk
+func (this *Matcher) scanChunk(ctx context.Context, r io.ReaderAt, size int64, lo int64, chunk int64, buf []byte) ([]Match, error) {
+    hi := min(lo+chunk, size)
+    end := min(hi+int64(this.longest), size)
+    buf = buf[:end-lo]
+    n, err := r.ReadAt(buf, lo)
+    if n < len(buf) {
+        if err == nil {
+            err = io.ErrUnexpectedEOF
+        }
+        return nil, err
+    }
+    var matches []Match
+    err = this.scanContext(ctx, buf, lo, func(m Match) {
+        if m.Start < hi {
+            matches = append(matches, m)
+        }
+    })
+    if err != nil {
+        return nil, err
+    }
+    sortMatches(matches)
+    return matches, nil
+}
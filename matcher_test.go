@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "context"
+import "reflect"
+import "strings"
+
+func TestMatcherFindAll(t *testing.T) {
+    trie := NewTrie()
+    trie.AddEntry("he", 1)
+    trie.AddEntry("she", 2)
+    trie.AddEntry("his", 3)
+    trie.AddEntry("hers", 4)
+    matches := trie.Compile().FindAll([]byte("ushers his"))
+    expected := []Match {
+        {1, 4, "she", 2},
+        {2, 4, "he", 1},
+        {2, 6, "hers", 4},
+        {7, 10, "his", 3},
+    }
+    if !reflect.DeepEqual(matches, expected) {
+        t.Errorf("Expected %v, got %v", expected, matches)
+    }
+}
+
+func TestScanParallel(t *testing.T) {
+    trie := NewTrie()
+    for _, key := range []string{"APPEARANCE", "APPEARANCES OF THE MARKINGS", "A", "CYLINDER", "THE CYLINDER"} {
+        trie.AddEntry(key, key)
+    }
+    matcher := trie.Compile()
+    text := strings.Repeat("THE APPEARANCES OF THE MARKINGS ON THE CYLINDER. ", 200)
+    expected := matcher.FindAll([]byte(text))
+    for _, chunk := range []int64{1, 7, 27, 100, 4096, int64(len(text))} {
+        for _, workers := range []int{1, 3, 8} {
+            matches, err := matcher.scanParallel(context.Background(), strings.NewReader(text), int64(len(text)), workers, chunk)
+            if err != nil {
+                t.Errorf("Unexpected error: %s", err)
+            }
+            if !reflect.DeepEqual(matches, expected) {
+                t.Errorf("Chunks of %d with %d workers found %d matches, expected %d", chunk, workers, len(matches), len(expected))
+            }
+        }
+    }
+    matches, _ := matcher.ScanParallel(context.Background(), strings.NewReader(text), int64(len(text)), 0)
+    if !reflect.DeepEqual(matches, expected) {
+        t.Errorf("ScanParallel found %d matches, expected %d", len(matches), len(expected))
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := matcher.scanParallel(ctx, strings.NewReader(text), int64(len(text)), 4, 10); err != context.Canceled {
+        t.Errorf("Expected the scan to be cancelled, got %v", err)
+    }
+    if _, err := matcher.ScanParallel(context.Background(), strings.NewReader(text), int64(len(text))+10, 4); err == nil {
+        t.Errorf("Expected an error reading past the end")
+    }
+}
+
+func TestFindAllContext(t *testing.T) {
+    trie := NewTrie()
+    trie.AddEntry("needle", 1)
+    matcher := trie.Compile()
+    text := []byte(strings.Repeat("hay", scanCheck) + "needle")
+    matches, err := matcher.FindAllContext(context.Background(), text)
+    if err != nil || len(matches) != 1 || matches[0].Start != int64(3*scanCheck) {
+        t.Errorf("Expected the needle at %d, got %v with %v", 3*scanCheck, matches, err)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := matcher.FindAllContext(ctx, text); err != context.Canceled {
+        t.Errorf("Expected the scan to be cancelled, got %v", err)
+    }
+}
@@ -0,0 +1,212 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+// MultiTrie keeps a postings list of values against each key rather than a
+// single value, so it can be used as an inverted index. Values must be
+// comparable, and each appears at most once per key.
+type MultiTrie struct {
+    entries *Trie
+}
+
+func NewMultiTrie() *MultiTrie {
+    return &MultiTrie {
+        entries: NewTrie(),
+    }
+}
+
+// postingsSetMin is how long a postings list gets before it is worth keeping
+// a set of its values to check for duplicates, most keys never get there
+const postingsSetMin = 16
+
+// postings is only ever appended to in place. Slices handed out are capped
+// at the length they had, so later appends land beyond what anyone can see,
+// and removals build a new slice.
+type postings struct {
+    values []interface{}
+    set map[interface{}]struct{} // nil while values is short
+}
+
+func (this *postings) contains(value interface{}) bool {
+    if this.set != nil {
+        _, found := this.set[value]
+        return found
+    }
+    for _, existing := range this.values {
+        if existing == value {
+            return true
+        }
+    }
+    return false
+}
+
+func (this *MultiTrie) postings(key string) []interface{} {
+    this.entries.lock.RLock()
+    defer this.entries.lock.RUnlock()
+    value, _ := this.entries.getEntry(key)
+    if value == nil {
+        return nil
+    }
+    values := value.(*postings).values
+    return values[:len(values):len(values)]
+}
+
+// Append adds value to the postings for key, returning false if it was
+// already there
+func (this *MultiTrie) Append(key string, value interface{}) (appended bool) {
+    this.entries.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+        p := &postings{}
+        if exists {
+            // a new postings sharing the old one's storage, so the trie sees
+            // a different value and counts this as a change
+            updated := *old.(*postings)
+            p = &updated
+        }
+        if p.contains(value) {
+            return old, exists
+        }
+        appended = true
+        p.values = append(p.values, value)
+        if p.set != nil {
+            p.set[value] = struct{}{}
+        } else if len(p.values) >= postingsSetMin {
+            p.set = make(map[interface{}]struct{}, len(p.values))
+            for _, v := range p.values {
+                p.set[v] = struct{}{}
+            }
+        }
+        return p, true
+    })
+    return appended
+}
+
+// Get returns the postings for key in the order they were appended. The
+// slice must not be modified.
+func (this *MultiTrie) Get(key string) []interface{} {
+    return this.postings(key)
+}
+
+// RemoveValue takes value out of the postings for key, removing the key
+// entirely once nothing is left
+func (this *MultiTrie) RemoveValue(key string, value interface{}) (removed bool) {
+    this.entries.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+        if !exists {
+            return nil, false
+        }
+        if !old.(*postings).contains(value) {
+            return old, true
+        }
+        updated := *old.(*postings)
+        p := &updated
+        removed = true
+        // a new slice, as the old one may have been handed out
+        values := make([]interface{}, 0, len(p.values)-1)
+        for _, existing := range p.values {
+            if existing != value {
+                values = append(values, existing)
+            }
+        }
+        p.values = values
+        if p.set != nil {
+            delete(p.set, value)
+        }
+        return p, len(values) > 0
+    })
+    return removed
+}
+
+// Remove drops a key and all of its postings
+func (this *MultiTrie) Remove(key string) {
+    this.entries.RemoveEntry(key)
+}
+
+// Len is the number of keys with postings
+func (this *MultiTrie) Len() int {
+    return this.entries.Len()
+}
+
+// Intersect returns the values found in the postings of every one of keys,
+// in the order of the shortest list
+func (this *MultiTrie) Intersect(keys ...string) []interface{} {
+    if len(keys) == 0 {
+        return nil
+    }
+    lists := make([][]interface{}, len(keys))
+    shortest := 0
+    for x, key := range keys {
+        lists[x] = this.postings(key)
+        if len(lists[x]) == 0 {
+            return nil
+        }
+        if len(lists[x]) < len(lists[shortest]) {
+            shortest = x
+        }
+    }
+    found := make(map[interface{}]int, len(lists[shortest]))
+    for _, value := range lists[shortest] {
+        found[value] = 0
+    }
+    for _, list := range lists {
+        for _, value := range list {
+            if count, exists := found[value]; exists {
+                found[value] = count+1
+            }
+        }
+    }
+    result := make([]interface{}, 0)
+    for _, value := range lists[shortest] {
+        if found[value] == len(lists) {
+            result = append(result, value)
+        }
+    }
+    return result
+}
+
+// Union returns the values found in the postings of any of keys, each once,
+// in the order they are first seen
+func (this *MultiTrie) Union(keys ...string) []interface{} {
+    seen := make(map[interface{}]bool)
+    result := make([]interface{}, 0)
+    for _, key := range keys {
+        for _, value := range this.postings(key) {
+            if !seen[value] {
+                seen[value] = true
+                result = append(result, value)
+            }
+        }
+    }
+    return result
+}
+
+// WithPrefix returns the values in the postings of every key starting with
+// prefix, each once
+func (this *MultiTrie) WithPrefix(prefix string) []interface{} {
+    keys := make([]string, 0)
+    for key := range this.entries.EntriesWithPrefix(prefix) {
+        keys = append(keys, key)
+    }
+    return this.Union(keys...)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+
+func TestMultiPostings(t *testing.T) {
+    index := NewMultiTrie()
+    docs := map[int]string {
+        1: "THE MARTIANS CAME",
+        2: "THE HEAT RAY",
+        3: "THE MARTIANS AND THE HEAT RAY",
+    }
+    for doc := 1; doc <= 3; doc++ {
+        for _, word := range Tokenize(docs[doc]) {
+            index.Append(word, doc)
+        }
+    }
+    if index.Append("THE", 1) {
+        t.Errorf("Appended a duplicate posting")
+    }
+    if found := fmt.Sprint(index.Get("THE")); found != "[1 2 3]" {
+        t.Errorf("Unexpected postings for THE: %s", found)
+    }
+    if found := fmt.Sprint(index.Intersect("MARTIANS", "HEAT")); found != "[3]" {
+        t.Errorf("Unexpected intersection: %s", found)
+    }
+    if found := index.Intersect("MARTIANS", "TRIPOD"); len(found) != 0 {
+        t.Errorf("Intersected with a missing key: %v", found)
+    }
+    if found := fmt.Sprint(index.Union("CAME", "RAY")); found != "[1 2 3]" {
+        t.Errorf("Unexpected union: %s", found)
+    }
+    if found := fmt.Sprint(index.WithPrefix("MAR")); found != "[1 3]" {
+        t.Errorf("Unexpected prefix postings: %s", found)
+    }
+
+    before := index.Get("HEAT")
+    if !index.RemoveValue("HEAT", 2) || index.RemoveValue("HEAT", 2) {
+        t.Errorf("Failed to remove posting exactly once")
+    }
+    if fmt.Sprint(before) != "[2 3]" {
+        t.Errorf("Removal changed a slice already handed out")
+    }
+    index.RemoveValue("HEAT", 3)
+    if _, validPath := index.entries.GetEntry("HEAT"); validPath {
+        t.Errorf("Key left behind with no postings")
+    }
+    keys := index.Len()
+    index.Remove("THE")
+    if index.Len() != keys-1 || index.Get("THE") != nil {
+        t.Errorf("Failed to remove key")
+    }
+}
+
+func TestMultiLongPostings(t *testing.T) {
+    index := NewMultiTrie()
+    for doc := 0; doc < 100000; doc++ {
+        index.Append("THE", doc)
+    }
+    before := index.Get("THE")
+    if index.Append("THE", 500) {
+        t.Errorf("Appended a duplicate to a long postings list")
+    }
+    index.Append("THE", -1)
+    if len(before) != 100000 || len(index.Get("THE")) != 100001 {
+        t.Errorf("Expected 100000 then 100001 postings, got %d and %d", len(before), len(index.Get("THE")))
+    }
+    if !index.RemoveValue("THE", 500) || index.Append("THE", 501) || !index.Append("THE", 500) {
+        t.Errorf("Set of postings out of step with the list")
+    }
+    if before[500] != 500 {
+        t.Errorf("Removal changed a slice already handed out")
+    }
+}
@@ -0,0 +1,329 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "bytes"
+    "context"
+    "iter"
+)
+
+// bytes below startLetter are stored at the end of children, so byte order
+// starts from here and wraps around
+const firstOrderedIndex = 256 - int(startLetter)
+
+// byteOrder calls fn with the indexes of a children slice of length n in byte
+// order, or reverse byte order if reverse is set, stopping early if fn
+// returns false
+func byteOrder(n int, reverse bool, fn func(index int) bool) bool {
+    wrapped := n
+    if wrapped > firstOrderedIndex {
+        wrapped = firstOrderedIndex
+    }
+    if reverse {
+        for x := wrapped-1; x >= 0; x-- {
+            if !fn(x) {
+                return false
+            }
+        }
+        for x := n-1; x >= firstOrderedIndex; x-- {
+            if !fn(x) {
+                return false
+            }
+        }
+        return true
+    }
+    for x := firstOrderedIndex; x < n; x++ {
+        if !fn(x) {
+            return false
+        }
+    }
+    for x := 0; x < wrapped; x++ {
+        if !fn(x) {
+            return false
+        }
+    }
+    return true
+}
+
+// eachChild calls fn for every child of t in byte order, or reverse byte
+// order if reverse is set, stopping early if fn returns false
+func eachChild(t *branch, reverse bool, fn func(b byte, child *branch) bool) bool {
+    return byteOrder(len(t.children), reverse, func(index int) bool {
+        return t.children[index] == nil || fn(keyByte(index), t.children[index])
+    })
+}
+
+// ascend yields the entries below t in order. key is the path to t not
+// including its shortcut. If bounded, only keys after from are yielded, or
+// from itself if inclusive is also set.
+func (this *Trie) ascend(t *branch, key []byte, from []byte, bounded bool, inclusive bool, yield func(string, interface{}) bool) bool {
+    key = append(key, t.shortcut...)
+    var next byte
+    if bounded {
+        m := len(key)
+        if len(from) < m {
+            m = len(from)
+        }
+        cmp := bytes.Compare(key[:m], from[:m])
+        switch {
+        case cmp < 0:
+            return true
+        case cmp > 0 || len(key) > len(from):
+            bounded = false
+        case len(key) == len(from):
+            if inclusive && t.value != nil && !yield(string(key), t.value) {
+                return false
+            }
+            return eachChild(t, false, func(b byte, child *branch) bool {
+                return this.ascend(child, append(key, b), nil, false, false, yield)
+            })
+        default:
+            next = from[len(key)]
+        }
+    }
+    if !bounded && t.value != nil && !yield(string(key), t.value) {
+        return false
+    }
+    return eachChild(t, false, func(b byte, child *branch) bool {
+        if bounded && b < next {
+            return true
+        }
+        return this.ascend(child, append(key, b), from, bounded && b == next, inclusive, yield)
+    })
+}
+
+// descend is ascend backwards, yielding the keys before to
+func (this *Trie) descend(t *branch, key []byte, to []byte, bounded bool, inclusive bool, yield func(string, interface{}) bool) bool {
+    key = append(key, t.shortcut...)
+    var next byte
+    if bounded {
+        m := len(key)
+        if len(to) < m {
+            m = len(to)
+        }
+        cmp := bytes.Compare(key[:m], to[:m])
+        switch {
+        case cmp > 0 || (cmp == 0 && len(key) > len(to)):
+            return true
+        case cmp < 0:
+            bounded = false
+        case len(key) == len(to):
+            if inclusive && t.value != nil {
+                return yield(string(key), t.value)
+            }
+            return true
+        default:
+            next = to[len(key)]
+        }
+    }
+    keep := eachChild(t, true, func(b byte, child *branch) bool {
+        if bounded && b > next {
+            return true
+        }
+        return this.descend(child, append(key, b), to, bounded && b == next, inclusive, yield)
+    })
+    if !keep {
+        return false
+    }
+    // the entry for t comes before everything below it
+    return t.value == nil || yield(string(key), t.value)
+}
+
+// readLocked holds the read lock for as long as a walk over the tree is
+// running. It is only for walks whose caller takes an entry or two and
+// stops, anything handed out to a loop goes through batched instead.
+func (this *Trie) readLocked(walk iter.Seq2[string, interface{}]) iter.Seq2[string, interface{}] {
+    return func(yield func(string, interface{}) bool) {
+        this.lock.RLock()
+        defer this.lock.RUnlock()
+        walk(yield)
+    }
+}
+
+// iterBatch is how many entries an iterator collects each time it takes the
+// read lock
+const iterBatch = 256
+
+type batchEntry struct {
+    key string
+    value interface{}
+}
+
+// batched runs walk a batch of entries at a time, holding the read lock only
+// while each batch is collected, so the body of the caller's loop is free to
+// use the trie, changes and all. walk is given the last key yielded, if
+// started is set, and carries on from the entry after it. Keys the loop
+// hasn't reached yet may or may not reflect changes made while it runs, but
+// none is yielded twice or out of order.
+func (this *Trie) batched(walk func(after []byte, started bool, yield func(string, interface{}) bool)) iter.Seq2[string, interface{}] {
+    return func(yield func(string, interface{}) bool) {
+        var after []byte
+        started := false
+        batch := make([]batchEntry, 0, iterBatch)
+        for {
+            batch = batch[:0]
+            this.lock.RLock()
+            walk(after, started, func(key string, value interface{}) bool {
+                batch = append(batch, batchEntry{key, value})
+                return len(batch) < iterBatch
+            })
+            this.lock.RUnlock()
+            for _, entry := range batch {
+                if !yield(entry.key, entry.value) {
+                    return
+                }
+            }
+            if len(batch) < iterBatch {
+                return
+            }
+            after, started = []byte(batch[len(batch)-1].key), true
+        }
+    }
+}
+
+// All yields every entry in byte order of its key
+func (this *Trie) All() iter.Seq2[string, interface{}] {
+    return this.batched(func(after []byte, started bool, yield func(string, interface{}) bool) {
+        this.ascend(this.tree, nil, after, started, false, yield)
+    })
+}
+
+// Descending yields every entry in reverse byte order of its key
+func (this *Trie) Descending() iter.Seq2[string, interface{}] {
+    return this.batched(func(after []byte, started bool, yield func(string, interface{}) bool) {
+        this.descend(this.tree, nil, after, started, false, yield)
+    })
+}
+
+// EntriesWithPrefix yields every entry whose key starts with prefix, in order
+func (this *Trie) EntriesWithPrefix(prefix string) iter.Seq2[string, interface{}] {
+    return this.batched(func(after []byte, started bool, yield func(string, interface{}) bool) {
+        t, off := this.tree, 0
+        for x := 0; x < len(prefix); x++ {
+            var ok bool
+            t, off, ok = this.step(t, off, prefix[x])
+            if !ok {
+                return
+            }
+        }
+        key := []byte(prefix[:len(prefix)-off])
+        this.ascend(t, key, after, started, false, yield)
+    })
+}
+
+// Range yields the entries with keys from from to to inclusive, in order
+func (this *Trie) Range(from string, to string) iter.Seq2[string, interface{}] {
+    return this.batched(func(after []byte, started bool, yield func(string, interface{}) bool) {
+        inclusive := !started
+        if !started {
+            after = []byte(from)
+        }
+        this.ascend(this.tree, nil, after, true, inclusive, func(key string, value interface{}) bool {
+            return key <= to && yield(key, value)
+        })
+    })
+}
+
+// walkContext calls fn with each entry of seq until fn returns false or ctx
+// is done, when it returns ctx.Err(). The context is looked at before every
+// entry, so a long walk stops promptly however little fn does.
+func walkContext(ctx context.Context, seq iter.Seq2[string, interface{}], fn func(key string, value interface{}) bool) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    for key, value := range seq {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if !fn(key, value) {
+            break
+        }
+    }
+    return nil
+}
+
+// AllContext calls fn with every entry in byte order of its key, like All,
+// stopping with ctx.Err() if ctx is cancelled part way
+func (this *Trie) AllContext(ctx context.Context, fn func(key string, value interface{}) bool) error {
+    return walkContext(ctx, this.All(), fn)
+}
+
+// EntriesWithPrefixContext is EntriesWithPrefix, stopping with ctx.Err() if
+// ctx is cancelled part way
+func (this *Trie) EntriesWithPrefixContext(ctx context.Context, prefix string, fn func(key string, value interface{}) bool) error {
+    return walkContext(ctx, this.EntriesWithPrefix(prefix), fn)
+}
+
+// RangeContext is Range, stopping with ctx.Err() if ctx is cancelled part way
+func (this *Trie) RangeContext(ctx context.Context, from string, to string, fn func(key string, value interface{}) bool) error {
+    return walkContext(ctx, this.Range(from, to), fn)
+}
+
+func (this *Trie) first(entries iter.Seq2[string, interface{}]) (key string, value interface{}, found bool) {
+    for k, v := range entries {
+        return k, v, true
+    }
+    return "", nil, false
+}
+
+// Min returns the entry with the smallest key
+func (this *Trie) Min() (key string, value interface{}, found bool) {
+    return this.first(this.All())
+}
+
+// Max returns the entry with the largest key
+func (this *Trie) Max() (key string, value interface{}, found bool) {
+    return this.first(this.Descending())
+}
+
+// Ceiling returns the entry with the smallest key >= key
+func (this *Trie) Ceiling(key string) (string, interface{}, bool) {
+    return this.first(this.readLocked(func(yield func(string, interface{}) bool) {
+        this.ascend(this.tree, nil, []byte(key), true, true, yield)
+    }))
+}
+
+// Successor returns the entry with the smallest key > key
+func (this *Trie) Successor(key string) (string, interface{}, bool) {
+    return this.first(this.readLocked(func(yield func(string, interface{}) bool) {
+        this.ascend(this.tree, nil, []byte(key), true, false, yield)
+    }))
+}
+
+// Floor returns the entry with the largest key <= key
+func (this *Trie) Floor(key string) (string, interface{}, bool) {
+    return this.first(this.readLocked(func(yield func(string, interface{}) bool) {
+        this.descend(this.tree, nil, []byte(key), true, true, yield)
+    }))
+}
+
+// Predecessor returns the entry with the largest key < key
+func (this *Trie) Predecessor(key string) (string, interface{}, bool) {
+    return this.first(this.readLocked(func(yield func(string, interface{}) bool) {
+        this.descend(this.tree, nil, []byte(key), true, false, yield)
+    }))
+}
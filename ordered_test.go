@@ -0,0 +1,224 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "context"
+import "fmt"
+import "sort"
+import "strings"
+
+var orderedKeys = []string {
+    "AB100", "AB150", "AB199", "AB2", "AB", "A", "ab100", "+1", " lead",
+    "你好", "你好世界", "ZZ", "[", ".", "..", "a-b", "a b", "~", "\x00",
+}
+
+func orderedTrie() *Trie {
+    trie := NewTrie()
+    for x, key := range orderedKeys {
+        trie.AddEntry(key, x)
+    }
+    return trie
+}
+
+func sortedKeys() []string {
+    keys := append([]string {}, orderedKeys...)
+    sort.Strings(keys)
+    return keys
+}
+
+func TestOrderedIteration(t *testing.T) {
+    trie := orderedTrie()
+    keys := sortedKeys()
+
+    found := make([]string, 0)
+    for key, value := range trie.All() {
+        if orderedKeys[value.(int)] != key {
+            t.Errorf("Wrong value for %s", key)
+        }
+        found = append(found, key)
+    }
+    if strings.Join(found, "|") != strings.Join(keys, "|") {
+        t.Errorf("Keys out of order: %q", found)
+    }
+
+    found = found[:0]
+    for key := range trie.Descending() {
+        found = append(found, key)
+    }
+    for x := range found {
+        if found[x] != keys[len(keys)-1-x] {
+            t.Errorf("Descending keys out of order: %q", found)
+            break
+        }
+    }
+
+    found = found[:0]
+    for key := range trie.EntriesWithPrefix("AB1") {
+        found = append(found, key)
+    }
+    if strings.Join(found, "|") != "AB100|AB150|AB199" {
+        t.Errorf("Unexpected prefix entries: %q", found)
+    }
+
+    found = found[:0]
+    for key := range trie.Range("AB100", "AB199") {
+        found = append(found, key)
+    }
+    if strings.Join(found, "|") != "AB100|AB150|AB199" {
+        t.Errorf("Unexpected range entries: %q", found)
+    }
+    found = found[:0]
+    for key := range trie.Range("AB0", "AB3") {
+        found = append(found, key)
+    }
+    if strings.Join(found, "|") != "AB100|AB150|AB199|AB2" {
+        t.Errorf("Unexpected range entries between missing keys: %q", found)
+    }
+}
+
+func TestOrderedNavigation(t *testing.T) {
+    trie := orderedTrie()
+    keys := sortedKeys()
+
+    if key, _, _ := trie.Min(); key != keys[0] {
+        t.Errorf("Wrong min %q", key)
+    }
+    if key, _, _ := trie.Max(); key != keys[len(keys)-1] {
+        t.Errorf("Wrong max %q", key)
+    }
+    for x, key := range keys {
+        if found, _, _ := trie.Floor(key); found != key {
+            t.Errorf("Floor of existing key %q gave %q", key, found)
+        }
+        if found, _, _ := trie.Ceiling(key); found != key {
+            t.Errorf("Ceiling of existing key %q gave %q", key, found)
+        }
+        found, _, ok := trie.Predecessor(key)
+        if (x == 0 && ok) || (x > 0 && found != keys[x-1]) {
+            t.Errorf("Predecessor of %q gave %q", key, found)
+        }
+        found, _, ok = trie.Successor(key)
+        if (x == len(keys)-1 && ok) || (x < len(keys)-1 && found != keys[x+1]) {
+            t.Errorf("Successor of %q gave %q", key, found)
+        }
+    }
+
+    if found, _, _ := trie.Floor("AB1999"); found != "AB199" {
+        t.Errorf("Floor of AB1999 gave %q", found)
+    }
+    if found, _, _ := trie.Ceiling("AB1"); found != "AB100" {
+        t.Errorf("Ceiling of AB1 gave %q", found)
+    }
+    if found, _, _ := trie.Floor("AB1"); found != "AB" {
+        t.Errorf("Floor of AB1 gave %q", found)
+    }
+    if _, _, ok := trie.Ceiling("\xff"); ok {
+        t.Errorf("Ceiling found past the last key")
+    }
+    empty := NewTrie()
+    if _, _, ok := empty.Min(); ok {
+        t.Errorf("Min found in an empty trie")
+    }
+}
+
+func TestIterationContext(t *testing.T) {
+    trie := orderedTrie()
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    seen := 0
+    err := trie.AllContext(ctx, func(key string, value interface{}) bool {
+        seen++
+        if seen == 3 {
+            cancel()
+        }
+        return true
+    })
+    if err != context.Canceled || seen != 3 {
+        t.Errorf("Expected to be cancelled after 3 entries, got %v after %d", err, seen)
+    }
+    if err := trie.EntriesWithPrefixContext(ctx, "AB", func(string, interface{}) bool { return true }); err != context.Canceled {
+        t.Errorf("Expected a cancelled context to stop the walk, got %v", err)
+    }
+
+    seen = 0
+    err = trie.RangeContext(context.Background(), "AB", "AB2", func(key string, value interface{}) bool {
+        seen++
+        return true
+    })
+    if err != nil || seen != 5 {
+        t.Errorf("Expected 5 entries in range, got %d with %v", seen, err)
+    }
+}
+
+func TestIterationAcrossBatches(t *testing.T) {
+    trie := NewTrie()
+    var keys []string
+    for x := 0; x < 3*iterBatch+17; x++ {
+        key := fmt.Sprintf("KEY %d", x)
+        keys = append(keys, key)
+        trie.AddEntry(key, x)
+    }
+    sort.Strings(keys)
+
+    var found []string
+    for key := range trie.All() {
+        found = append(found, key)
+    }
+    if strings.Join(found, "|") != strings.Join(keys, "|") {
+        t.Errorf("All went wrong across batches, got %d keys", len(found))
+    }
+    found = found[:0]
+    for key := range trie.Descending() {
+        found = append(found, key)
+    }
+    for x := range found {
+        if found[x] != keys[len(keys)-1-x] {
+            t.Errorf("Descending went wrong across batches at %d: %s", x, found[x])
+            break
+        }
+    }
+    count := 0
+    for range trie.Range(keys[10], keys[600]) {
+        count++
+    }
+    if count != 591 {
+        t.Errorf("Expected 591 keys in range, got %d", count)
+    }
+
+    // the loop body can use the trie, writes included
+    removed := 0
+    for key := range trie.EntriesWithPrefix("KEY 1") {
+        if value, _ := trie.GetEntry(key); value == nil {
+            t.Errorf("Lost %s part way through the loop", key)
+        }
+        trie.RemoveEntry(key)
+        removed++
+    }
+    if removed != 1+10+100 || trie.Len() != len(keys)-removed {
+        t.Errorf("Removed %d keys inside the loop, leaving %d", removed, trie.Len())
+    }
+}
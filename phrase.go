@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "strings"
+    "unicode"
+)
+
+// PhraseTrie is keyed by sequences of whole tokens instead of bytes, so a
+// scan over a body of text can move forward a word at a time from wherever
+// it got to rather than looking the whole phrase up again.
+type PhraseTrie struct {
+    root *PhraseNode
+}
+
+// PhraseNode is a position in a PhraseTrie, reached by stepping through
+// tokens from the root
+type PhraseNode struct {
+    children map[string]*PhraseNode
+    value interface{}
+}
+
+func NewPhraseTrie() *PhraseTrie {
+    return &PhraseTrie {
+        root: &PhraseNode{},
+    }
+}
+
+// Tokenize splits text on whitespace and trims the punctuation from either
+// end of each word, dropping anything left empty
+func Tokenize(text string) []string {
+    words := strings.Fields(text)
+    tokens := words[:0]
+    for _, word := range words {
+        word = strings.TrimFunc(word, unicode.IsPunct)
+        if word != "" {
+            tokens = append(tokens, word)
+        }
+    }
+    return tokens
+}
+
+func (this *PhraseTrie) AddPhrase(tokens []string, value interface{}) {
+    node := this.root
+    for _, token := range tokens {
+        next := node.children[token]
+        if next == nil {
+            if node.children == nil {
+                node.children = make(map[string]*PhraseNode)
+            }
+            next = &PhraseNode{}
+            node.children[token] = next
+        }
+        node = next
+    }
+    node.value = value
+}
+
+// GetPhrase works like GetEntry, validPath is true if some phrase starts
+// with tokens
+func (this *PhraseTrie) GetPhrase(tokens []string) (value interface{}, validPath bool) {
+    node := this.root
+    for _, token := range tokens {
+        node = this.Step(node, token)
+        if node == nil {
+            return nil, false
+        }
+    }
+    return node.value, true
+}
+
+func (this *PhraseTrie) Root() *PhraseNode {
+    return this.root
+}
+
+// Step follows a single token on from node, returning nil if no phrase
+// continues that way. Stepping on from nil gives nil, so steps can be
+// chained without checking each one.
+func (this *PhraseTrie) Step(node *PhraseNode, token string) *PhraseNode {
+    if node == nil {
+        return nil
+    }
+    return node.children[token]
+}
+
+// Value is the value of the phrase ending at this node, or nil if the node
+// is only part of the way through a phrase or is nil
+func (this *PhraseNode) Value() interface{} {
+    if this == nil {
+        return nil
+    }
+    return this.value
+}
+
+// HasChildren reports whether any longer phrase continues from this node
+func (this *PhraseNode) HasChildren() bool {
+    return this != nil && len(this.children) > 0
+}
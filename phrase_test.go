@@ -0,0 +1,86 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "strings"
+
+func TestPhraseSteps(t *testing.T) {
+    phrases := NewPhraseTrie()
+    phrases.AddPhrase(Tokenize("APPEARANCE OF A HUGE CYLINDER"), "1")
+    phrases.AddPhrase(Tokenize("APPEARANCE OF A"), "2")
+    phrases.AddPhrase(Tokenize("ITS STRANGE APPEARANCE"), "3")
+
+    node := phrases.Step(phrases.Step(phrases.Step(phrases.Root(), "ITS"), "ODD"), "APPEARANCE")
+    if node != nil || node.Value() != nil || node.HasChildren() {
+        t.Errorf("Stepping on from a dead end should stay at nil")
+    }
+
+    val, validPath := phrases.GetPhrase([]string {"APPEARANCE", "OF"})
+    if val != nil || !validPath {
+        t.Errorf("Subphrase not identified as a valid path")
+    }
+    val, validPath = phrases.GetPhrase([]string {"APPEARANCE", "OF", "A"})
+    if val.(string) != "2" || !validPath {
+        t.Errorf("Failed to retrieve shorter phrase")
+    }
+    _, validPath = phrases.GetPhrase([]string {"APPEARANCE OF"})
+    if validPath {
+        t.Errorf("Joined tokens should not match")
+    }
+
+    text := Tokenize("the  appearance of a huge cylinder, and its strange appearance.")
+    found := make([]string, 0)
+    for x := 0; x < len(text); x++ {
+        node := phrases.Root()
+        out := ""
+        end := x
+        for y := x; y < len(text); y++ {
+            node = phrases.Step(node, strings.ToUpper(text[y]))
+            if node == nil {
+                break
+            }
+            if node.Value() != nil {
+                out = node.Value().(string)
+                end = y
+            }
+        }
+        if out != "" {
+            found = append(found, out)
+            x = end
+        }
+    }
+    if strings.Join(found, ",") != "1,3" {
+        t.Errorf("Unexpected phrases found: %v", found)
+    }
+}
+
+func TestTokenize(t *testing.T) {
+    tokens := Tokenize("  Hello,   world!  -- \"quoted\" it's\n")
+    if strings.Join(tokens, "|") != "Hello|world|quoted|it's" {
+        t.Errorf("Unexpected tokens: %v", tokens)
+    }
+}
@@ -0,0 +1,314 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "bytes"
+    "encoding/gob"
+    "fmt"
+    "iter"
+    "net/netip"
+)
+
+// PrefixTable maps IPv4 and IPv6 CIDR blocks to values and finds the longest
+// prefix covering an address. It's a binary trie where, much like
+// branch.shortcut, runs of bits with no decisions in them are collapsed into
+// a single node.
+type PrefixTable struct {
+    v4 *prefixNode
+    v6 *prefixNode
+    size int
+}
+
+type prefixNode struct {
+    // all of the bits that lead to this node, the child taken is decided by
+    // the bit straight after them
+    prefix netip.Prefix
+    children [2]*prefixNode
+    value interface{}
+    set bool
+}
+
+func NewPrefixTable() *PrefixTable {
+    return &PrefixTable{}
+}
+
+func (this *PrefixTable) root(addr netip.Addr) **prefixNode {
+    if addr.Is4() {
+        return &this.v4
+    }
+    return &this.v6
+}
+
+// bitAt returns bit n of the address, counting from the most significant
+func bitAt(addr netip.Addr, n int) int {
+    b := addr.AsSlice()
+    return int(b[n/8] >> (7 - uint(n%8))) & 1
+}
+
+// commonBits is the length of the prefix shared by a and b
+func commonBits(a netip.Prefix, b netip.Prefix) int {
+    limit := a.Bits()
+    if b.Bits() < limit {
+        limit = b.Bits()
+    }
+    ab := a.Addr().AsSlice()
+    bb := b.Addr().AsSlice()
+    var x int
+    for x = 0; x < limit && ab[x/8] == bb[x/8] && x+8 <= limit; x += 8 {
+    }
+    for ; x < limit && bitAt(a.Addr(), x) == bitAt(b.Addr(), x); x++ {
+    }
+    return x
+}
+
+func normalisePrefix(p netip.Prefix) (netip.Prefix, error) {
+    if !p.IsValid() {
+        return p, fmt.Errorf("invalid prefix %s", p)
+    }
+    if p.Addr().Is4In6() && p.Bits() >= 96 {
+        p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+    }
+    return p.Masked(), nil
+}
+
+// Insert sets the value for a prefix, replacing any existing value
+func (this *PrefixTable) Insert(p netip.Prefix, value interface{}) error {
+    p, err := normalisePrefix(p)
+    if err != nil {
+        return err
+    }
+    n := this.root(p.Addr())
+    for {
+        cur := *n
+        if cur == nil {
+            *n = &prefixNode {
+                prefix: p,
+                value: value,
+                set: true,
+            }
+            this.size++
+            return nil
+        }
+        common := commonBits(cur.prefix, p)
+        if common == cur.prefix.Bits() && common == p.Bits() {
+            // we are here, set it and forget it
+            if !cur.set {
+                this.size++
+            }
+            cur.value = value
+            cur.set = true
+            return nil
+        }
+        if common == cur.prefix.Bits() {
+            // p sits somewhere below cur
+            n = &cur.children[bitAt(p.Addr(), common)]
+            continue
+        }
+        // split cur at the first differing bit, p either takes the split
+        // point or becomes the sibling of cur
+        split := &prefixNode {
+            prefix: netip.PrefixFrom(p.Addr(), common).Masked(),
+        }
+        split.children[bitAt(cur.prefix.Addr(), common)] = cur
+        if common == p.Bits() {
+            split.value = value
+            split.set = true
+        } else {
+            split.children[bitAt(p.Addr(), common)] = &prefixNode {
+                prefix: p,
+                value: value,
+                set: true,
+            }
+        }
+        *n = split
+        this.size++
+        return nil
+    }
+}
+
+// Delete removes a prefix, returning its value if it was present. Nodes left
+// without a value or a choice to make are collapsed back into their parent.
+func (this *PrefixTable) Delete(p netip.Prefix) (value interface{}, deleted bool) {
+    p, err := normalisePrefix(p)
+    if err != nil {
+        return nil, false
+    }
+    n := this.root(p.Addr())
+    var parent **prefixNode
+    for *n != nil && (*n).prefix.Bits() < p.Bits() && (*n).prefix.Contains(p.Addr()) {
+        parent = n
+        n = &(*n).children[bitAt(p.Addr(), (*n).prefix.Bits())]
+    }
+    cur := *n
+    if cur == nil || cur.prefix != p || !cur.set {
+        return nil, false
+    }
+    value = cur.value
+    cur.value = nil
+    cur.set = false
+    this.size--
+    prune(n)
+    if parent != nil {
+        prune(parent)
+    }
+    return value, true
+}
+
+// prune removes or collapses a node that no longer holds a value
+func prune(n **prefixNode) {
+    cur := *n
+    if cur.set {
+        return
+    }
+    switch {
+    case cur.children[0] == nil:
+        *n = cur.children[1]
+    case cur.children[1] == nil:
+        *n = cur.children[0]
+    }
+}
+
+// Get returns the value stored for exactly this prefix
+func (this *PrefixTable) Get(p netip.Prefix) (value interface{}, found bool) {
+    p, err := normalisePrefix(p)
+    if err != nil {
+        return nil, false
+    }
+    cur := *this.root(p.Addr())
+    for cur != nil && cur.prefix.Bits() < p.Bits() && cur.prefix.Contains(p.Addr()) {
+        cur = cur.children[bitAt(p.Addr(), cur.prefix.Bits())]
+    }
+    if cur == nil || cur.prefix != p || !cur.set {
+        return nil, false
+    }
+    return cur.value, true
+}
+
+// Lookup returns the longest stored prefix containing addr
+func (this *PrefixTable) Lookup(addr netip.Addr) (prefix netip.Prefix, value interface{}, found bool) {
+    if !addr.IsValid() {
+        return prefix, nil, false
+    }
+    addr = addr.Unmap().WithZone("")
+    bits := addr.BitLen()
+    for cur := *this.root(addr); cur != nil && cur.prefix.Contains(addr); {
+        if cur.set {
+            prefix, value, found = cur.prefix, cur.value, true
+        }
+        if cur.prefix.Bits() == bits {
+            break
+        }
+        cur = cur.children[bitAt(addr, cur.prefix.Bits())]
+    }
+    return prefix, value, found
+}
+
+// Contains reports whether any stored prefix covers addr
+func (this *PrefixTable) Contains(addr netip.Addr) bool {
+    _, _, found := this.Lookup(addr)
+    return found
+}
+
+// Len is the number of prefixes stored
+func (this *PrefixTable) Len() int {
+    return this.size
+}
+
+// Overlapping yields every stored prefix that overlaps p, that is both the
+// shorter prefixes containing it and the longer ones inside it, in order
+func (this *PrefixTable) Overlapping(p netip.Prefix) iter.Seq2[netip.Prefix, interface{}] {
+    return func(yield func(netip.Prefix, interface{}) bool) {
+        p, err := normalisePrefix(p)
+        if err != nil {
+            return
+        }
+        cur := *this.root(p.Addr())
+        for cur != nil && cur.prefix.Bits() < p.Bits() && cur.prefix.Contains(p.Addr()) {
+            if cur.set && !yield(cur.prefix, cur.value) {
+                return
+            }
+            cur = cur.children[bitAt(p.Addr(), cur.prefix.Bits())]
+        }
+        if cur != nil && p.Contains(cur.prefix.Addr()) {
+            walkPrefixes(cur, yield)
+        }
+    }
+}
+
+// All yields every stored prefix, IPv4 before IPv6 and shorter prefixes
+// before the longer ones inside them
+func (this *PrefixTable) All() iter.Seq2[netip.Prefix, interface{}] {
+    return func(yield func(netip.Prefix, interface{}) bool) {
+        if walkPrefixes(this.v4, yield) {
+            walkPrefixes(this.v6, yield)
+        }
+    }
+}
+
+func walkPrefixes(n *prefixNode, yield func(netip.Prefix, interface{}) bool) bool {
+    if n == nil {
+        return true
+    }
+    if n.set && !yield(n.prefix, n.value) {
+        return false
+    }
+    return walkPrefixes(n.children[0], yield) && walkPrefixes(n.children[1], yield)
+}
+
+type prefixEntry struct {
+    Prefix netip.Prefix
+    Value interface{}
+}
+
+// MarshalBinary gob encodes the table. Values are stored as interfaces, so
+// anything other than the basic types needs to be passed to gob.Register.
+func (this *PrefixTable) MarshalBinary() ([]byte, error) {
+    entries := make([]prefixEntry, 0, this.size)
+    for p, v := range this.All() {
+        entries = append(entries, prefixEntry{p, v})
+    }
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode(entries); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the contents of the table with the encoded one
+func (this *PrefixTable) UnmarshalBinary(data []byte) error {
+    var entries []prefixEntry
+    if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+        return err
+    }
+    *this = PrefixTable{}
+    for _, entry := range entries {
+        if err := this.Insert(entry.Prefix, entry.Value); err != nil {
+            return err
+        }
+    }
+    return nil
+}
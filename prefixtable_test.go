@@ -0,0 +1,116 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "net/netip"
+
+func TestPrefixLongestMatch(t *testing.T) {
+    table := NewPrefixTable()
+    prefixes := []string {
+        "10.0.0.0/8",
+        "10.1.0.0/16",
+        "10.1.2.0/24",
+        "192.168.0.0/16",
+        "0.0.0.0/0",
+        "2001:db8::/32",
+        "2001:db8:1::/48",
+    }
+    for _, p := range prefixes {
+        if err := table.Insert(netip.MustParsePrefix(p), p); err != nil {
+            t.Errorf("Unable to insert %s: %s", p, err)
+        }
+    }
+    if table.Len() != len(prefixes) {
+        t.Errorf("Expected %d prefixes, got %d", len(prefixes), table.Len())
+    }
+
+    lookups := map[string]string {
+        "10.1.2.3": "10.1.2.0/24",
+        "10.1.3.3": "10.1.0.0/16",
+        "10.2.3.4": "10.0.0.0/8",
+        "8.8.8.8": "0.0.0.0/0",
+        "::ffff:192.168.1.1": "192.168.0.0/16",
+        "2001:db8:1::1": "2001:db8:1::/48",
+        "2001:db8:2::1": "2001:db8::/32",
+    }
+    for addr, expected := range lookups {
+        prefix, val, found := table.Lookup(netip.MustParseAddr(addr))
+        if !found || val.(string) != expected || prefix.String() != expected {
+            t.Errorf("Lookup of %s gave %s, expected %s", addr, prefix, expected)
+        }
+    }
+    if table.Contains(netip.MustParseAddr("2001:db9::1")) {
+        t.Errorf("Address outside of all IPv6 prefixes was contained")
+    }
+
+    val, deleted := table.Delete(netip.MustParsePrefix("10.1.0.0/16"))
+    if !deleted || val.(string) != "10.1.0.0/16" {
+        t.Errorf("Failed to delete 10.1.0.0/16")
+    }
+    _, deleted = table.Delete(netip.MustParsePrefix("10.1.0.0/16"))
+    if deleted {
+        t.Errorf("Deleted the same prefix twice")
+    }
+    prefix, _, _ := table.Lookup(netip.MustParseAddr("10.1.3.3"))
+    if prefix.String() != "10.0.0.0/8" {
+        t.Errorf("Lookup after delete gave %s", prefix)
+    }
+    prefix, _, _ = table.Lookup(netip.MustParseAddr("10.1.2.3"))
+    if prefix.String() != "10.1.2.0/24" {
+        t.Errorf("Lost the more specific prefix after delete, got %s", prefix)
+    }
+}
+
+func TestPrefixOverlapping(t *testing.T) {
+    table := NewPrefixTable()
+    for _, p := range []string {"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.2.0.0/16", "11.0.0.0/8"} {
+        table.Insert(netip.MustParsePrefix(p), p)
+    }
+    found := ""
+    for p := range table.Overlapping(netip.MustParsePrefix("10.1.0.0/16")) {
+        found += p.String() + " "
+    }
+    if found != "10.0.0.0/8 10.1.0.0/16 10.1.2.0/24 " {
+        t.Errorf("Unexpected overlapping prefixes: %s", found)
+    }
+
+    data, err := table.MarshalBinary()
+    if err != nil {
+        t.Errorf("Unable to marshal table: %s", err)
+    }
+    copied := NewPrefixTable()
+    if err = copied.UnmarshalBinary(data); err != nil {
+        t.Errorf("Unable to unmarshal table: %s", err)
+    }
+    if copied.Len() != table.Len() {
+        t.Errorf("Expected %d prefixes after round trip, got %d", table.Len(), copied.Len())
+    }
+    val, exists := copied.Get(netip.MustParsePrefix("10.2.0.0/16"))
+    if !exists || val.(string) != "10.2.0.0/16" {
+        t.Errorf("Value lost in round trip")
+    }
+}
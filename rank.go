@@ -0,0 +1,132 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "bytes"
+
+// Len is the number of entries in the trie
+func (this *Trie) Len() int {
+    this.lock.RLock()
+    defer this.lock.RUnlock()
+    return this.tree.count
+}
+
+// Rank is the number of keys that sort before key, whether or not key itself
+// is present
+func (this *Trie) Rank(key string) int {
+    this.lock.RLock()
+    defer this.lock.RUnlock()
+    return this.rank(key)
+}
+
+func (this *Trie) rank(key string) int {
+    rank := 0
+    rem := []byte(key)
+    t := this.tree
+    for {
+        s := t.shortcut
+        m := len(s)
+        if len(rem) < m {
+            m = len(rem)
+        }
+        switch cmp := bytes.Compare(s[:m], rem[:m]); {
+        case cmp < 0:
+            return rank + t.count
+        case cmp > 0 || len(s) > len(rem):
+            return rank
+        }
+        rem = rem[len(s):]
+        if len(rem) == 0 {
+            return rank
+        }
+        if t.value != nil {
+            rank++
+        }
+        var next *branch
+        eachChild(t, false, func(b byte, child *branch) bool {
+            if b == rem[0] {
+                next = child
+            }
+            if b >= rem[0] {
+                return false
+            }
+            rank += child.count
+            return true
+        })
+        if next == nil {
+            return rank
+        }
+        t = next
+        rem = rem[1:]
+    }
+}
+
+// Select returns the entry at position i in key order, counting from zero,
+// or a nil value if there aren't that many entries
+func (this *Trie) Select(i int) (string, interface{}) {
+    this.lock.RLock()
+    defer this.lock.RUnlock()
+    if i < 0 || i >= this.tree.count {
+        return "", nil
+    }
+    key := make([]byte, 0)
+    t := this.tree
+    for t != nil {
+        key = append(key, t.shortcut...)
+        if t.value != nil {
+            if i == 0 {
+                return string(key), t.value
+            }
+            i--
+        }
+        var next *branch
+        eachChild(t, false, func(b byte, child *branch) bool {
+            if i < child.count {
+                key = append(key, b)
+                next = child
+                return false
+            }
+            i -= child.count
+            return true
+        })
+        t = next
+    }
+    return "", nil
+}
+
+// CountRange is the number of keys from from to to inclusive
+func (this *Trie) CountRange(from string, to string) int {
+    if from > to {
+        return 0
+    }
+    this.lock.RLock()
+    defer this.lock.RUnlock()
+    count := this.rank(to) - this.rank(from)
+    if value, _ := this.getEntry(to); value != nil {
+        count++
+    }
+    return count
+}
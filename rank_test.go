@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+
+func TestRankSelect(t *testing.T) {
+    trie := orderedTrie()
+    keys := sortedKeys()
+    if trie.Len() != len(keys) {
+        t.Errorf("Expected %d entries, got %d", len(keys), trie.Len())
+    }
+    for x, key := range keys {
+        if rank := trie.Rank(key); rank != x {
+            t.Errorf("Rank of %q was %d, expected %d", key, rank, x)
+        }
+        if found, _ := trie.Select(x); found != key {
+            t.Errorf("Select %d gave %q, expected %q", x, found, key)
+        }
+    }
+    if _, value := trie.Select(len(keys)); value != nil {
+        t.Errorf("Select past the end returned a value")
+    }
+    if rank := trie.Rank("AB10"); rank != trie.Rank("AB100") {
+        t.Errorf("Rank of a missing key should count the keys before it")
+    }
+    if count := trie.CountRange("AB100", "AB199"); count != 3 {
+        t.Errorf("Expected 3 keys in range, got %d", count)
+    }
+    if count := trie.CountRange("AB0", "AB3"); count != 4 {
+        t.Errorf("Expected 4 keys in range, got %d", count)
+    }
+}
+
+func TestRemoveEntry(t *testing.T) {
+    trie := NewTrie()
+    for x := 0; x < 200; x++ {
+        trie.AddEntry(fmt.Sprintf("KEY %d", x), x)
+    }
+    trie.AddEntry("KEY", -1)
+    trie.AddEntry("KEY 1", "replaced")
+    if trie.Len() != 201 {
+        t.Errorf("Expected 201 entries, got %d", trie.Len())
+    }
+
+    if _, removed := trie.RemoveEntry("KEY 1000"); removed {
+        t.Errorf("Removed a missing entry")
+    }
+    if _, removed := trie.RemoveEntry("KEY "); removed {
+        t.Errorf("Removed a subpath")
+    }
+    for x := 0; x < 200; x += 2 {
+        value, removed := trie.RemoveEntry(fmt.Sprintf("KEY %d", x))
+        if !removed || value.(int) != x {
+            t.Errorf("Failed to remove KEY %d", x)
+        }
+    }
+    if trie.Len() != 101 {
+        t.Errorf("Expected 101 entries after removal, got %d", trie.Len())
+    }
+    for x := 0; x < 200; x++ {
+        value, _ := trie.GetEntry(fmt.Sprintf("KEY %d", x))
+        if (x%2 == 0) != (value == nil) {
+            t.Errorf("Wrong value for KEY %d after removal", x)
+        }
+    }
+    value, _ := trie.GetEntry("KEY")
+    if value.(int) != -1 {
+        t.Errorf("Lost the parent entry")
+    }
+
+    trie.RemoveEntry("KEY")
+    for x := 1; x < 200; x += 2 {
+        trie.RemoveEntry(fmt.Sprintf("KEY %d", x))
+    }
+    if trie.Len() != 0 {
+        t.Errorf("Expected an empty trie, got %d entries", trie.Len())
+    }
+    if _, validPath := trie.GetEntry("KEY"); validPath {
+        t.Errorf("Emptied trie still has a path")
+    }
+    trie.AddEntry("KEY", 1)
+    if value, _ := trie.GetEntry("KEY"); value.(int) != 1 {
+        t.Errorf("Unable to reuse an emptied trie")
+    }
+}
@@ -0,0 +1,203 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "io"
+
+// rewriter replaces matches in text fed to it a piece at a time. Where
+// matches overlap the one starting first wins, and of those starting
+// together the longest, with the search carrying on after it. Text is only
+// held back while it could still be part of a match: the depth of the
+// automaton's state is the most it could be.
+type rewriter struct {
+    matcher *Matcher
+    fn func(Match) string
+    s int32
+    pos int64 // bytes taken in
+    done int64 // bytes either written out or replaced
+    pending []byte // the text from done to pos
+    candidates []Match // the longest match found at each start from done
+    out []byte // output waiting to be collected
+}
+
+func (this *Matcher) newRewriter(fn func(Match) string) *rewriter {
+    return &rewriter {
+        matcher: this,
+        fn: fn,
+    }
+}
+
+func (this *rewriter) write(text []byte) {
+    m := this.matcher
+    for _, b := range text {
+        this.s = m.next(this.s, b)
+        this.pending = append(this.pending, b)
+        this.pos++
+        for o := this.s; o >= 0; o = m.states[o].output {
+            if p := m.states[o].pattern; p >= 0 {
+                this.found(Match{this.pos - int64(len(m.patterns[p].key)), this.pos, m.patterns[p].key, m.patterns[p].value})
+            }
+        }
+        this.settle(this.pos - int64(m.states[this.s].depth))
+    }
+}
+
+func (this *rewriter) found(match Match) {
+    if match.Start < this.done {
+        return
+    }
+    for x, c := range this.candidates {
+        if c.Start == match.Start {
+            if match.End > c.End {
+                this.candidates[x] = match
+            }
+            return
+        }
+    }
+    this.candidates = append(this.candidates, match)
+}
+
+// settle deals with everything before limit, no match yet to be found can
+// start there
+func (this *rewriter) settle(limit int64) {
+    for {
+        best := -1
+        for x, c := range this.candidates {
+            if best < 0 || c.Start < this.candidates[best].Start {
+                best = x
+            }
+        }
+        if best < 0 || this.candidates[best].Start >= limit {
+            break
+        }
+        match := this.candidates[best]
+        this.out = append(this.out, this.pending[:match.Start-this.done]...)
+        this.out = append(this.out, this.fn(match)...)
+        this.pending = this.pending[match.End-this.done:]
+        this.done = match.End
+        left := this.candidates[:0]
+        for _, c := range this.candidates {
+            if c.Start >= this.done {
+                left = append(left, c)
+            }
+        }
+        this.candidates = left
+    }
+    if limit > this.done {
+        this.out = append(this.out, this.pending[:limit-this.done]...)
+        this.pending = this.pending[limit-this.done:]
+        this.done = limit
+    }
+}
+
+// close settles the rest of the text, there being no more to come
+func (this *rewriter) close() {
+    this.settle(this.pos)
+}
+
+// take hands over the output so far
+func (this *rewriter) take() []byte {
+    out := this.out
+    this.out = nil
+    return out
+}
+
+// ReplaceAll returns text with each match replaced by whatever fn returns
+// for it. Matches don't overlap: the leftmost wins, the longest of those
+// starting at the same place, and the search carries on after it.
+func (this *Matcher) ReplaceAll(text string, fn func(m Match) string) string {
+    r := this.newRewriter(fn)
+    r.out = make([]byte, 0, len(text))
+    r.write([]byte(text))
+    r.close()
+    return string(r.out)
+}
+
+type replaceWriter struct {
+    w io.Writer
+    r *rewriter
+}
+
+// ReplaceWriter returns a writer that makes the same replacements as
+// ReplaceAll on everything written to it, passing the result on to w. Up to
+// Longest bytes can be held back waiting to see if they are part of a match,
+// so it has to be closed once everything has been written. Closing it
+// doesn't close w.
+func (this *Matcher) ReplaceWriter(w io.Writer, fn func(m Match) string) io.WriteCloser {
+    return &replaceWriter{w, this.newRewriter(fn)}
+}
+
+func (this *replaceWriter) Write(p []byte) (int, error) {
+    this.r.write(p)
+    if _, err := this.w.Write(this.r.take()); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
+
+func (this *replaceWriter) Close() error {
+    this.r.close()
+    _, err := this.w.Write(this.r.take())
+    return err
+}
+
+type replaceReader struct {
+    src io.Reader
+    r *rewriter
+    buf []byte
+    out []byte
+    err error
+}
+
+// ReplaceReader returns a reader of what is read from src with the same
+// replacements as ReplaceAll made to it
+func (this *Matcher) ReplaceReader(src io.Reader, fn func(m Match) string) io.Reader {
+    return &replaceReader {
+        src: src,
+        r: this.newRewriter(fn),
+        buf: make([]byte, 32 << 10),
+    }
+}
+
+func (this *replaceReader) Read(p []byte) (int, error) {
+    for len(this.out) == 0 && this.err == nil {
+        n, err := this.src.Read(this.buf)
+        this.r.write(this.buf[:n])
+        if err != nil {
+            this.err = err
+            if err == io.EOF {
+                this.r.close()
+            }
+        }
+        this.out = this.r.take()
+    }
+    n := copy(p, this.out)
+    this.out = this.out[n:]
+    if len(this.out) == 0 && this.err != nil {
+        return n, this.err
+    }
+    return n, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "bytes"
+import "io"
+import "strings"
+import "testing/iotest"
+
+func replaceMatcher() *Matcher {
+    trie := NewTrie()
+    trie.AddEntry("he", "HE")
+    trie.AddEntry("she", "SHE")
+    trie.AddEntry("hers", "HERS")
+    trie.AddEntry("ushers", "USHERS")
+    trie.AddEntry("his", "HIS")
+    trie.AddEntry("is a", "IS-A")
+    return trie.Compile()
+}
+
+func upper(m Match) string {
+    return "<" + m.Value.(string) + ">"
+}
+
+func TestReplaceAll(t *testing.T) {
+    matcher := replaceMatcher()
+    tests := map[string]string {
+        "":                   "",
+        "nothing here?":      "nothing <HE>re?",
+        "ushers":             "<USHERS>",
+        "the usher":          "t<HE> u<SHE>r",
+        "shers":              "<SHE>rs",
+        "hershis":            "<HERS><HIS>",
+        "this is a test":     "t<HIS> <IS-A> test",
+        "hhhe":               "hh<HE>",
+    }
+    for text, expected := range tests {
+        if replaced := matcher.ReplaceAll(text, upper); replaced != expected {
+            t.Errorf("Replacing in %q expected %q, got %q", text, expected, replaced)
+        }
+    }
+}
+
+func TestReplaceStreaming(t *testing.T) {
+    matcher := replaceMatcher()
+    text := strings.Repeat("this is a test of the ushers, and his hers she said. ", 50)
+    expected := matcher.ReplaceAll(text, upper)
+
+    var out bytes.Buffer
+    w := matcher.ReplaceWriter(&out, upper)
+    for x := 0; x < len(text); x += 3 {
+        w.Write([]byte(text[x:min(x+3, len(text))]))
+    }
+    w.Close()
+    if out.String() != expected {
+        t.Errorf("ReplaceWriter differs from ReplaceAll:\n%s\n%s", out.String(), expected)
+    }
+
+    replaced, err := io.ReadAll(matcher.ReplaceReader(iotest.OneByteReader(strings.NewReader(text)), upper))
+    if err != nil {
+        t.Errorf("Unexpected error: %s", err)
+    }
+    if string(replaced) != expected {
+        t.Errorf("ReplaceReader differs from ReplaceAll:\n%s\n%s", replaced, expected)
+    }
+}
@@ -0,0 +1,154 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Router matches paths against routes built from static segments, ":name"
+// segments capturing a single level and a final "*name" segment capturing
+// the rest of the path. Where several routes could match, a static segment
+// wins over a parameter and a parameter wins over a catch-all.
+//
+// Routes are kept in a regular Trie with the parameter names stripped out,
+// so "/users/:id" is stored as "/users/:" and the shortcuts work as usual.
+type Router struct {
+    routes *Trie
+}
+
+// Params maps the parameter names of the matched route to their values
+type Params map[string]string
+
+type route struct {
+    pattern string
+    names []string
+    value interface{}
+}
+
+func NewRouter() *Router {
+    return &Router {
+        routes: NewTrie(),
+    }
+}
+
+// parseRoute turns a pattern into the key we store it under and the names of
+// its parameters in order
+func parseRoute(pattern string) (string, []string, error) {
+    segments := strings.Split(pattern, "/")
+    names := make([]string, 0)
+    for x, segment := range segments {
+        if len(segment) == 0 || (segment[0] != ':' && segment[0] != '*') {
+            continue
+        }
+        name := segment[1:]
+        if name == "" {
+            return "", nil, fmt.Errorf("route %s: unnamed parameter in segment %d", pattern, x)
+        }
+        if segment[0] == '*' && x != len(segments)-1 {
+            return "", nil, fmt.Errorf("route %s: catch-all %s must be the last segment", pattern, segment)
+        }
+        for _, existing := range names {
+            if existing == name {
+                return "", nil, fmt.Errorf("route %s: parameter %s used twice", pattern, name)
+            }
+        }
+        names = append(names, name)
+        segments[x] = segment[:1]
+    }
+    return strings.Join(segments, "/"), names, nil
+}
+
+// AddRoute registers a pattern, failing if it is malformed or if an existing
+// route would match exactly the same paths
+func (this *Router) AddRoute(pattern string, value interface{}) error {
+    key, names, err := parseRoute(pattern)
+    if err != nil {
+        return err
+    }
+    existing, loaded := this.routes.LoadOrStore(key, &route {
+        pattern: pattern,
+        names: names,
+        value: value,
+    })
+    if loaded {
+        return fmt.Errorf("route %s conflicts with existing route %s", pattern, existing.(*route).pattern)
+    }
+    return nil
+}
+
+// Lookup finds the highest priority route matching path
+func (this *Router) Lookup(path string) (value interface{}, params Params, found bool) {
+    this.routes.lock.RLock()
+    r, captures := this.match(this.routes.tree, 0, []byte(path), 0, nil)
+    this.routes.lock.RUnlock()
+    if r == nil {
+        return nil, nil, false
+    }
+    params = make(Params, len(r.names))
+    for x, name := range r.names {
+        params[name] = captures[x]
+    }
+    return r.value, params, true
+}
+
+// match tries static, then parameter, then catch-all children from the given
+// position, backtracking when a more specific branch doesn't pan out
+func (this *Router) match(t *branch, off int, path []byte, pos int, captures []string) (*route, []string) {
+    segmentStart := pos == 0 || path[pos-1] == '/'
+
+    if pos == len(path) {
+        if off == len(t.shortcut) && t.value != nil {
+            return t.value.(*route), captures
+        }
+    } else if !segmentStart || (path[pos] != ':' && path[pos] != '*') {
+        if nt, noff, ok := this.routes.step(t, off, path[pos]); ok {
+            if r, c := this.match(nt, noff, path, pos+1, captures); r != nil {
+                return r, c
+            }
+        }
+    }
+
+    if !segmentStart {
+        return nil, nil
+    }
+    if pt, poff, ok := this.routes.step(t, off, ':'); ok {
+        end := pos
+        for end < len(path) && path[end] != '/' {
+            end++
+        }
+        if end > pos {
+            if r, c := this.match(pt, poff, path, end, append(captures, string(path[pos:end]))); r != nil {
+                return r, c
+            }
+        }
+    }
+    if ct, coff, ok := this.routes.step(t, off, '*'); ok && coff == len(ct.shortcut) && ct.value != nil {
+        return ct.value.(*route), append(captures, string(path[pos:]))
+    }
+    return nil, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+
+func TestRouterPriority(t *testing.T) {
+    router := NewRouter()
+    routes := []string {
+        "/users/:id",
+        "/users/new",
+        "/users/:id/posts/:post",
+        "/files/*path",
+        "/files/readme",
+        "/:section/about",
+    }
+    for _, route := range routes {
+        if err := router.AddRoute(route, route); err != nil {
+            t.Errorf("Unable to add %s: %s", route, err)
+        }
+    }
+
+    val, params, found := router.Lookup("/users/new")
+    if !found || val.(string) != "/users/new" || len(params) != 0 {
+        t.Errorf("Static segment should win over parameter")
+    }
+    val, params, found = router.Lookup("/users/42")
+    if !found || val.(string) != "/users/:id" || params["id"] != "42" {
+        t.Errorf("Failed to capture id parameter")
+    }
+    val, params, found = router.Lookup("/users/42/posts/7")
+    if !found || params["id"] != "42" || params["post"] != "7" {
+        t.Errorf("Failed to capture nested parameters")
+    }
+    val, params, found = router.Lookup("/users/new/posts/7")
+    if !found || val.(string) != "/users/:id/posts/:post" || params["id"] != "new" {
+        t.Errorf("Failed to backtrack from static segment to parameter")
+    }
+    val, params, found = router.Lookup("/files/docs/a.txt")
+    if !found || val.(string) != "/files/*path" || params["path"] != "docs/a.txt" {
+        t.Errorf("Failed to capture catch-all")
+    }
+    val, params, found = router.Lookup("/files/readme")
+    if !found || val.(string) != "/files/readme" {
+        t.Errorf("Static segment should win over catch-all")
+    }
+    val, params, found = router.Lookup("/teams/about")
+    if !found || val.(string) != "/:section/about" || params["section"] != "teams" {
+        t.Errorf("Failed to backtrack to root parameter")
+    }
+    _, _, found = router.Lookup("/users/")
+    if found {
+        t.Errorf("Parameters should not match an empty segment")
+    }
+    _, _, found = router.Lookup("/users/:id")
+    if !found {
+        t.Errorf("Literal colon should be captured by the parameter")
+    }
+}
+
+func TestRouterConflicts(t *testing.T) {
+    router := NewRouter()
+    if err := router.AddRoute("/users/:id", "1"); err != nil {
+        t.Errorf("Unable to add route: %s", err)
+    }
+    if err := router.AddRoute("/users/:name", "2"); err == nil {
+        t.Errorf("Conflicting parameter route not reported")
+    }
+    if err := router.AddRoute("/files/*path/more", "3"); err == nil {
+        t.Errorf("Catch-all before the last segment not reported")
+    }
+    if err := router.AddRoute("/a/:x/:x", "4"); err == nil {
+        t.Errorf("Duplicate parameter name not reported")
+    }
+    if err := router.AddRoute("/a/:", "5"); err == nil {
+        t.Errorf("Unnamed parameter not reported")
+    }
+    val, _, _ := router.Lookup("/users/1")
+    if val.(string) != "1" {
+        t.Errorf("Conflicting route replaced the original")
+    }
+}
@@ -44,15 +44,16 @@ func findTree() {
     strcontents = strings.Replace(strcontents, "\n", " ", -1)
     strcontents = strings.Replace(strcontents, "\r", " ", -1)
     words := strings.Split(strcontents, " ")
-    tmp := ""
     foundEntries := make([]string, 0)
     for x := 0; x < len(words); x++ {
-        tmp = ""
+        // the cursor carries on from the previous word instead of
+        // looking the whole phrase up again
+        cursor := tree.Root()
         out := ""
         y := 0
         for ;y < 100 && (x+y) < len(words); y++ {
-            if y > 0 {
-                tmp += " "
+            if y > 0 && !cursor.Next(' ') {
+                break
             }
             wrd := words[x+y]
             // strip off some common grammar
@@ -64,13 +65,11 @@ func findTree() {
                     wrd = wrd[:len(wrd)-1]
                 }
             }
-            tmp += wrd
-            value, validPath := tree.GetEntry(tmp)
-            if !validPath {
+            if !cursor.NextString(wrd) {
                 break
             }
-            if value != nil {
-                out = value.(string)
+            if cursor.IsKey() {
+                out = cursor.Value().(string)
             }
         }
         if out != "" {
@@ -82,6 +81,64 @@ func findTree() {
     fmt.Println(foundEntries)
 }
 
+func findPhraseTrie() {
+    tree := trie.NewPhraseTrie()
+    tree.AddPhrase(trie.Tokenize("APPEARANCE OF A HUGE CYLINDER"), "1")
+    tree.AddPhrase(trie.Tokenize("APPEARANCES OF THE MARKINGS"), "2")
+    tree.AddPhrase(trie.Tokenize("ITS STRANGE APPEARANCE"), "3")
+    tree.AddPhrase(trie.Tokenize("WIMBLEDON PARTICULARLY HAD SUFFERED"), "4")
+
+    // get the file contents
+    contents, _ := ioutil.ReadFile("war of the worlds.txt")
+    words := trie.Tokenize(strings.ToUpper(string(contents)))
+    foundEntries := make([]string, 0)
+    for x := 0; x < len(words); x++ {
+        node := tree.Root()
+        out := ""
+        end := x
+        // carry on from the last node rather than the root
+        for y := x; y < len(words); y++ {
+            node = tree.Step(node, words[y])
+            if node == nil {
+                break
+            }
+            if node.Value() != nil {
+                out = node.Value().(string)
+                end = y
+            }
+        }
+        if out != "" {
+            foundEntries = append(foundEntries, out)
+            x = end
+        }
+    }
+    fmt.Print("Found: ")
+    fmt.Println(foundEntries)
+}
+
+func replaceMatcher() {
+    tree := trie.NewTrie()
+    tree.AddEntry("APPEARANCE OF A HUGE CYLINDER", "1")
+    tree.AddEntry("APPEARANCES OF THE MARKINGS", "2")
+    tree.AddEntry("ITS STRANGE APPEARANCE", "3")
+    tree.AddEntry("WIMBLEDON PARTICULARLY HAD SUFFERED", "4")
+    matcher := tree.Compile()
+
+    // get the file contents
+    contents, _ := ioutil.ReadFile("war of the worlds.txt")
+    strcontents := strings.ToUpper(string(contents))
+    strcontents = strings.Replace(strcontents, "\n", " ", -1)
+    strcontents = strings.Replace(strcontents, "\r", " ", -1)
+    // mark each phrase in place instead of just listing them
+    foundEntries := make([]string, 0)
+    matcher.ReplaceAll(strcontents, func(m trie.Match) string {
+        foundEntries = append(foundEntries, m.Value.(string))
+        return "[" + m.Value.(string) + "]"
+    })
+    fmt.Print("Found: ")
+    fmt.Println(foundEntries)
+}
+
 func findHashMap() {
     tree := make(map[string]string,5)
     tree["APPEARANCE OF A HUGE CYLINDER"] = "1"
@@ -138,5 +195,11 @@ func main() {
     fmt.Println("Test Trie:")
     findTree()
     fmt.Println(time.Now())
+    fmt.Println("Test Phrase Trie:")
+    findPhraseTrie()
+    fmt.Println(time.Now())
+    fmt.Println("Test Matcher Replace:")
+    replaceMatcher()
+    fmt.Println(time.Now())
 
 }
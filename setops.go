@@ -0,0 +1,270 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "sync/atomic"
+
+// Resolve picks the value for a key found in both tries, returning nil drops
+// the key altogether
+type Resolve func(key string, va interface{}, vb interface{}) interface{}
+
+type setOp int
+
+const (
+    opUnion setOp = iota
+    opIntersect
+    opDifference
+)
+
+// Union returns a new trie holding the keys of both a and b. Where a key is
+// in both, resolve decides the value, or b's value is used if resolve is nil.
+func Union(a *Trie, b *Trie, resolve Resolve) *Trie {
+    return combine(a, b, opUnion, resolve)
+}
+
+// Intersect returns a new trie holding the keys in both a and b. resolve
+// decides the value, or a's value is used if resolve is nil.
+func Intersect(a *Trie, b *Trie, resolve Resolve) *Trie {
+    return combine(a, b, opIntersect, resolve)
+}
+
+// Difference returns a new trie holding the keys of a that aren't in b
+func Difference(a *Trie, b *Trie) *Trie {
+    return combine(a, b, opDifference, nil)
+}
+
+var lastTrieID atomic.Uint64
+
+// ident gives each trie a number the first time it is asked for one
+func (this *Trie) ident() uint64 {
+    if id := this.id.Load(); id != 0 {
+        return id
+    }
+    this.id.CompareAndSwap(0, lastTrieID.Add(1))
+    return this.id.Load()
+}
+
+// lockBoth locks a, for writing if write is set, and read locks b, which may
+// be the same trie. The one with the lower ident is always locked first, so
+// two goroutines locking the same pair the other way round can't deadlock.
+func lockBoth(a *Trie, write bool, b *Trie) func() {
+    lockA, unlockA := a.lock.RLock, a.lock.RUnlock
+    if write {
+        lockA, unlockA = a.lock.Lock, a.lock.Unlock
+    }
+    if b == a {
+        lockA()
+        return unlockA
+    }
+    if a.ident() < b.ident() {
+        lockA()
+        b.lock.RLock()
+    } else {
+        b.lock.RLock()
+        lockA()
+    }
+    return func() {
+        b.lock.RUnlock()
+        unlockA()
+    }
+}
+
+// readLockBoth read locks a pair of tries, which may be the same one
+func readLockBoth(a *Trie, b *Trie) func() {
+    return lockBoth(a, false, b)
+}
+
+func combine(a *Trie, b *Trie, op setOp, resolve Resolve) *Trie {
+    defer readLockBoth(a, b)()
+    result := NewTrie()
+    result.augment = a.augment
+    if tree := result.merge(a.tree, 0, b.tree, 0, nil, op, resolve, false); tree != nil {
+        result.tree = tree
+    } else {
+        result.refresh(result.tree)
+    }
+    return result
+}
+
+// MergeFrom adds the entries of other to this trie, resolving keys in both as
+// Union does. Subtrees with nothing from other in them are kept as they are.
+func (this *Trie) MergeFrom(other *Trie, resolve Resolve) {
+    if other == this {
+        return
+    }
+    defer this.dispatch()
+    defer lockBoth(this, true, other)()
+    this.version++
+    tree := this.merge(this.tree, 0, other.tree, 0, nil, opUnion, resolve, true)
+    if tree == nil {
+        tree = &branch{}
+        this.refresh(tree)
+    }
+    this.tree = tree
+}
+
+// copyBranch copies the subtree found off bytes into t's shortcut. With reuse
+// set the existing branches are used instead where possible.
+func (this *Trie) copyBranch(t *branch, off int, reuse bool) *branch {
+    if reuse && off == 0 {
+        return t
+    }
+    c := &branch {
+        shortcut: t.shortcut[off:],
+        value: t.value,
+    }
+    if reuse {
+        c.children = t.children
+    } else if t.children != nil {
+        c.children = make([]*branch, len(t.children))
+        for y, child := range t.children {
+            if child != nil {
+                c.children[y] = this.copyBranch(child, 0, false)
+            }
+        }
+    }
+    this.refresh(c)
+    return c
+}
+
+// splitAt describes a position off bytes into t's shortcut as if t had been
+// split there. Either we're sitting on t and get its value and children, or
+// the shortcut runs on and next is the index of the single child it leads to.
+func (this *Trie) splitAt(t *branch, off int) (value interface{}, children []*branch, next int) {
+    if off == len(t.shortcut) {
+        return t.value, t.children, -1
+    }
+    return nil, nil, this.GetKey(t.shortcut[off])
+}
+
+// childAt picks out child y of a position described by splitAt, along with
+// how far into its shortcut the child starts
+func childAt(t *branch, off int, children []*branch, next int, y int) (*branch, int) {
+    if y == next {
+        return t, off+1
+    }
+    if y < len(children) {
+        return children[y], 0
+    }
+    return nil, 0
+}
+
+// childrenLen is the length of children needed to hold the children of both
+// positions
+func childrenLen(achildren []*branch, anext int, bchildren []*branch, bnext int) int {
+    n := len(achildren)
+    if len(bchildren) > n {
+        n = len(bchildren)
+    }
+    if anext >= n {
+        n = anext+1
+    }
+    if bnext >= n {
+        n = bnext+1
+    }
+    return n
+}
+
+// merge walks the subtrees found off bytes into the shortcuts of a and b in
+// lockstep, splitting whichever shortcut runs on longer so the two line up.
+// It returns nil if nothing is left of them.
+func (this *Trie) merge(a *branch, aoff int, b *branch, boff int, key []byte, op setOp, resolve Resolve, reuse bool) *branch {
+    switch {
+    case a == nil && b == nil:
+        return nil
+    case b == nil:
+        if op == opIntersect {
+            return nil
+        }
+        return this.copyBranch(a, aoff, reuse)
+    case a == nil:
+        if op != opUnion {
+            return nil
+        }
+        if this.watching() {
+            this.ascend(b, key[:len(key)-boff], nil, false, false, func(k string, v interface{}) bool {
+                this.notify(EventPut, k, v)
+                return true
+            })
+        }
+        return this.copyBranch(b, boff, false)
+    }
+
+    sa := a.shortcut[aoff:]
+    sb := b.shortcut[boff:]
+    var l int
+    for l = 0; l < len(sa) && l < len(sb) && sa[l] == sb[l]; l++ {
+    }
+    t := &branch {
+        shortcut: append(make([]byte, 0, l), sa[:l]...),
+    }
+    key = append(key, sa[:l]...)
+
+    va, achildren, anext := this.splitAt(a, aoff+l)
+    vb, bchildren, bnext := this.splitAt(b, boff+l)
+
+    switch op {
+    case opUnion:
+        t.value = va
+        if vb != nil {
+            t.value = vb
+            if va != nil && resolve != nil {
+                t.value = resolve(string(key), va, vb)
+            }
+            if this.watching() && !sameValue(va, t.value) {
+                this.notify(EventPut, string(key), t.value)
+            }
+        }
+    case opIntersect:
+        if va != nil && vb != nil {
+            t.value = va
+            if resolve != nil {
+                t.value = resolve(string(key), va, vb)
+            }
+        }
+    case opDifference:
+        if vb == nil {
+            t.value = va
+        }
+    }
+
+    n := childrenLen(achildren, anext, bchildren, bnext)
+    t.children = make([]*branch, n)
+    for y := 0; y < n; y++ {
+        ca, caoff := childAt(a, aoff+l, achildren, anext, y)
+        cb, cboff := childAt(b, boff+l, bchildren, bnext, y)
+        if ca != nil || cb != nil {
+            t.children[y] = this.merge(ca, caoff, cb, cboff, append(key, keyByte(y)), op, resolve, reuse)
+        }
+    }
+
+    this.collapse(t)
+    if t.shortcut == nil {
+        return nil
+    }
+    this.refresh(t)
+    return t
+}
@@ -0,0 +1,147 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "math/rand"
+import "fmt"
+import "runtime"
+import "sync"
+import "time"
+
+func randomSet(r *rand.Rand, n int, tag string) (*Trie, map[string]string) {
+    trie := NewTrie()
+    entries := make(map[string]string)
+    letters := "AB +你"
+    for x := 0; x < n; x++ {
+        key := make([]byte, r.Intn(6))
+        for y := range key {
+            key[y] = letters[r.Intn(len(letters))]
+        }
+        trie.AddEntry(string(key), tag)
+        entries[string(key)] = tag
+    }
+    return trie, entries
+}
+
+func checkSet(t *testing.T, name string, trie *Trie, expected map[string]string) {
+    if trie.Len() != len(expected) {
+        t.Errorf("%s: expected %d entries, got %d", name, len(expected), trie.Len())
+    }
+    for key, value := range trie.All() {
+        if expected[key] != value.(string) {
+            t.Errorf("%s: unexpected value %s for %q", name, value, key)
+        }
+    }
+}
+
+func TestSetAlgebra(t *testing.T) {
+    r := rand.New(rand.NewSource(1))
+    resolve := func(key string, va interface{}, vb interface{}) interface{} {
+        return va.(string) + vb.(string)
+    }
+    for round := 0; round < 50; round++ {
+        a, ea := randomSet(r, r.Intn(40), "a")
+        b, eb := randomSet(r, r.Intn(40), "b")
+        union := make(map[string]string)
+        intersect := make(map[string]string)
+        difference := make(map[string]string)
+        for key, value := range ea {
+            union[key] = value
+            if _, exists := eb[key]; exists {
+                intersect[key] = "ab"
+            } else {
+                difference[key] = value
+            }
+        }
+        for key, value := range eb {
+            union[key] = union[key] + value
+        }
+        name := fmt.Sprintf("round %d", round)
+        checkSet(t, name+" union", Union(a, b, resolve), union)
+        checkSet(t, name+" intersect", Intersect(a, b, resolve), intersect)
+        checkSet(t, name+" difference", Difference(a, b), difference)
+
+        a.MergeFrom(b, resolve)
+        checkSet(t, name+" merge", a, union)
+        checkSet(t, name+" merge source", b, eb)
+    }
+}
+
+func TestSetAlgebraCopies(t *testing.T) {
+    a := NewTrie()
+    a.AddEntry("shure asdf", "1")
+    b := NewTrie()
+    b.AddEntry("shura no toki", "2")
+    union := Union(a, b, nil)
+    union.AddEntry("shure asdg", "3")
+    union.RemoveEntry("shura no toki")
+    if a.Len() != 1 || b.Len() != 1 {
+        t.Errorf("Changing the union changed its inputs")
+    }
+    if value, _ := b.GetEntry("shura no toki"); value.(string) != "2" {
+        t.Errorf("Lost the entry from b")
+    }
+    if Intersect(a, b, nil).Len() != 0 {
+        t.Errorf("Disjoint tries intersected")
+    }
+}
+
+func TestMergeBothWays(t *testing.T) {
+    // more threads than CPUs gets the locking interleaved every which way
+    defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+    a, b := NewTrie(), NewTrie()
+    var wg sync.WaitGroup
+    run := func(fn func(x int)) {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for x := 0; x < 10000; x++ {
+                fn(x)
+            }
+        }()
+    }
+    run(func(x int) { a.MergeFrom(b, nil) })
+    run(func(x int) { b.MergeFrom(a, nil) })
+    run(func(x int) { a.AddEntry(fmt.Sprintf("a%d", x % 50), x) })
+    run(func(x int) { b.AddEntry(fmt.Sprintf("b%d", x % 50), x) })
+    run(func(x int) { Union(b, a, nil) })
+    done := make(chan struct{})
+    go func() {
+        wg.Wait()
+        close(done)
+    }()
+    select {
+    case <-done:
+    case <-time.After(30 * time.Second):
+        t.Fatalf("Merging two tries into each other deadlocked")
+    }
+    a.MergeFrom(b, nil)
+    b.MergeFrom(a, nil)
+    if a.Len() != 100 || b.Len() != 100 {
+        t.Errorf("Expected both tries to end up with 100 entries, got %d and %d", a.Len(), b.Len())
+    }
+}
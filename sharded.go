@@ -0,0 +1,161 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "iter"
+
+// ShardedTrie splits its entries over a number of independent tries, each
+// with its own lock, so writers to different shards don't wait on each
+// other. Reads that cover more than one key merge the shards back into key
+// order, so apart from not being atomic across shards it behaves like a
+// single Trie.
+type ShardedTrie struct {
+    shards []*Trie
+    shardOf func(key string) int
+    leading bool // shardOf only looks at the first byte
+}
+
+// NewShardedTrie returns a trie with the given number of shards, picked by
+// the first byte of each key. Every key with the same first byte lands in
+// the same shard, so a prefix query only has to look at one of them.
+func NewShardedTrie(shards int) *ShardedTrie {
+    this := NewShardedTrieFunc(shards, func(key string) int {
+        if key == "" {
+            return 0
+        }
+        return int(key[0])
+    })
+    this.leading = true
+    return this
+}
+
+// NewShardedTrieFunc returns a trie with the given number of shards, where
+// shardOf picks the shard for a key. Its result is taken modulo the number of
+// shards. Prefix queries have to look at every shard.
+func NewShardedTrieFunc(shards int, shardOf func(key string) int) *ShardedTrie {
+    if shards < 1 {
+        shards = 1
+    }
+    this := &ShardedTrie {
+        shards: make([]*Trie, shards),
+        shardOf: shardOf,
+    }
+    for x := range this.shards {
+        this.shards[x] = NewTrie()
+    }
+    return this
+}
+
+func (this *ShardedTrie) shard(key string) *Trie {
+    index := this.shardOf(key) % len(this.shards)
+    if index < 0 {
+        index += len(this.shards)
+    }
+    return this.shards[index]
+}
+
+func (this *ShardedTrie) AddEntry(key string, value interface{}) {
+    this.shard(key).AddEntry(key, value)
+}
+
+func (this *ShardedTrie) GetEntry(key string) (value interface{}, validPath bool) {
+    return this.shard(key).GetEntry(key)
+}
+
+func (this *ShardedTrie) RemoveEntry(key string) (value interface{}, removed bool) {
+    return this.shard(key).RemoveEntry(key)
+}
+
+// Update works as it does for Trie, only the key's own shard is locked
+func (this *ShardedTrie) Update(key string, fn func(old interface{}, exists bool) (value interface{}, keep bool)) {
+    this.shard(key).Update(key, fn)
+}
+
+// Len is the number of entries across all the shards
+func (this *ShardedTrie) Len() int {
+    count := 0
+    for _, shard := range this.shards {
+        count += shard.Len()
+    }
+    return count
+}
+
+// All yields every entry in byte order of its key, a batch at a time from
+// each shard as Trie.All does
+func (this *ShardedTrie) All() iter.Seq2[string, interface{}] {
+    seqs := make([]iter.Seq2[string, interface{}], len(this.shards))
+    for x, shard := range this.shards {
+        seqs[x] = shard.All()
+    }
+    return mergeOrdered(seqs)
+}
+
+// EntriesWithPrefix yields every entry whose key starts with prefix, in order
+func (this *ShardedTrie) EntriesWithPrefix(prefix string) iter.Seq2[string, interface{}] {
+    if this.leading && prefix != "" {
+        return this.shard(prefix).EntriesWithPrefix(prefix)
+    }
+    seqs := make([]iter.Seq2[string, interface{}], len(this.shards))
+    for x, shard := range this.shards {
+        seqs[x] = shard.EntriesWithPrefix(prefix)
+    }
+    return mergeOrdered(seqs)
+}
+
+// mergeOrdered yields the entries of several ordered sequences, with no key
+// in more than one of them, as a single ordered sequence
+func mergeOrdered(seqs []iter.Seq2[string, interface{}]) iter.Seq2[string, interface{}] {
+    type head struct {
+        next func() (string, interface{}, bool)
+        key string
+        value interface{}
+        ok bool
+    }
+    return func(yield func(string, interface{}) bool) {
+        heads := make([]head, len(seqs))
+        for x, seq := range seqs {
+            next, stop := iter.Pull2(seq)
+            defer stop()
+            heads[x].next = next
+            heads[x].key, heads[x].value, heads[x].ok = next()
+        }
+        for {
+            min := -1
+            for x := range heads {
+                if heads[x].ok && (min < 0 || heads[x].key < heads[min].key) {
+                    min = x
+                }
+            }
+            if min < 0 {
+                return
+            }
+            if !yield(heads[min].key, heads[min].value) {
+                return
+            }
+            heads[min].key, heads[min].value, heads[min].ok = heads[min].next()
+        }
+    }
+}
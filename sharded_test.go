@@ -0,0 +1,83 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+import "hash/fnv"
+import "sort"
+import "sync"
+
+func TestShardedTrieOrder(t *testing.T) {
+    hashed := NewShardedTrieFunc(7, func(key string) int {
+        h := fnv.New32a()
+        h.Write([]byte(key))
+        return int(h.Sum32())
+    })
+    for _, trie := range []*ShardedTrie{NewShardedTrie(32), hashed} {
+        var wg sync.WaitGroup
+        var keys []string
+        for w := 0; w < 32; w++ {
+            for x := 0; x < 50; x++ {
+                keys = append(keys, fmt.Sprintf("%c%d/%d", 'a'+x%26, w, x))
+            }
+            wg.Add(1)
+            go func(w int) {
+                defer wg.Done()
+                for x := 0; x < 50; x++ {
+                    trie.AddEntry(fmt.Sprintf("%c%d/%d", 'a'+x%26, w, x), w)
+                }
+            }(w)
+        }
+        wg.Wait()
+        sort.Strings(keys)
+        if trie.Len() != len(keys) {
+            t.Errorf("Expected %d entries, got %d", len(keys), trie.Len())
+        }
+        x := 0
+        for key := range trie.All() {
+            if x < len(keys) && key != keys[x] {
+                t.Errorf("Expected %s at %d, got %s", keys[x], x, key)
+            }
+            x++
+        }
+
+        var prefixed []string
+        for key := range trie.EntriesWithPrefix("c1") {
+            prefixed = append(prefixed, key)
+        }
+        if len(prefixed) != 22 || !sort.StringsAreSorted(prefixed) {
+            t.Errorf("Expected 22 ordered entries under c1, got %v", prefixed)
+        }
+        if value, _ := trie.GetEntry("b7/1"); value != 7 {
+            t.Errorf("Expected 7 for b7/1, got %v", value)
+        }
+        trie.RemoveEntry("b7/1")
+        if value, _ := trie.GetEntry("b7/1"); value != nil {
+            t.Errorf("Failed to remove b7/1")
+        }
+    }
+}
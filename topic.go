@@ -0,0 +1,125 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "context"
+    "iter"
+)
+
+// TopicTrie stores MQTT style topic filters as keys. Within a stored filter
+// '+' stands in for exactly one level and '#' for any number of trailing
+// levels, a level being whatever sits between separators.
+type TopicTrie struct {
+    *Trie
+    separator byte
+}
+
+func NewTopicTrie(separator byte) *TopicTrie {
+    return &TopicTrie {
+        Trie: NewTrie(),
+        separator: separator,
+    }
+}
+
+// MatchingFilters yields every stored filter that matches the concrete topic,
+// along with its value. A level of the topic starting with a wildcard is
+// only matched by wildcards, never literally. The matches are all found before the first is
+// yielded, so the trie isn't locked while the loop runs.
+func (this *TopicTrie) MatchingFilters(topic string) iter.Seq2[string, interface{}] {
+    return func(yield func(string, interface{}) bool) {
+        var found []batchEntry
+        this.lock.RLock()
+        this.matchFilters(this.tree, 0, []byte(topic), 0, nil, func(filter string, value interface{}) bool {
+            found = append(found, batchEntry{filter, value})
+            return true
+        })
+        this.lock.RUnlock()
+        for _, entry := range found {
+            if !yield(entry.key, entry.value) {
+                return
+            }
+        }
+    }
+}
+
+// MatchingFiltersContext is MatchingFilters, stopping with ctx.Err() if ctx
+// is cancelled part way
+func (this *TopicTrie) MatchingFiltersContext(ctx context.Context, topic string, fn func(filter string, value interface{}) bool) error {
+    return walkContext(ctx, this.MatchingFilters(topic), fn)
+}
+
+// matchFilters walks both the literal and wildcard children from the given
+// position, returning false once yield has asked us to stop
+func (this *TopicTrie) matchFilters(t *branch, off int, topic []byte, pos int, filter []byte, yield func(string, interface{}) bool) bool {
+    if pos == 0 || topic[pos-1] == this.separator {
+        // wildcards only count when they take up a whole level
+        if wt, woff, ok := this.step(t, off, '#'); ok {
+            if woff == len(wt.shortcut) && wt.value != nil {
+                if !yield(string(filter)+"#", wt.value) {
+                    return false
+                }
+            }
+        }
+        if wt, woff, ok := this.step(t, off, '+'); ok {
+            end := pos
+            for end < len(topic) && topic[end] != this.separator {
+                end++
+            }
+            if !this.matchFilters(wt, woff, topic, end, append(filter, '+'), yield) {
+                return false
+            }
+        }
+    }
+
+    if pos == len(topic) {
+        if off == len(t.shortcut) && t.value != nil {
+            if !yield(string(filter), t.value) {
+                return false
+            }
+        }
+        // "a/#" matches "a" as well
+        if pos > 0 {
+            if st, soff, ok := this.step(t, off, this.separator); ok {
+                if wt, woff, ok := this.step(st, soff, '#'); ok && woff == len(wt.shortcut) && wt.value != nil {
+                    return yield(string(filter)+string(this.separator)+"#", wt.value)
+                }
+            }
+        }
+        return true
+    }
+
+    if (pos == 0 || topic[pos-1] == this.separator) && (topic[pos] == '+' || topic[pos] == '#') {
+        // topics can't hold wildcards, and following one literally would
+        // find the filters the wildcard branches above already have
+        return true
+    }
+    nt, noff, ok := this.step(t, off, topic[pos])
+    if !ok {
+        return true
+    }
+    return this.matchFilters(nt, noff, topic, pos+1, append(filter, topic[pos]), yield)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "context"
+import "sort"
+import "strings"
+
+func matchedFilters(trie *TopicTrie, topic string) string {
+    found := make([]string, 0)
+    for filter := range trie.MatchingFilters(topic) {
+        found = append(found, filter)
+    }
+    sort.Strings(found)
+    return strings.Join(found, " ")
+}
+
+func TestTopicWildcards(t *testing.T) {
+    trie := NewTopicTrie('/')
+    trie.AddEntry("sensors/+/temp", "1")
+    trie.AddEntry("sensors/kitchen/temp", "2")
+    trie.AddEntry("sensors/#", "3")
+    trie.AddEntry("alerts/#", "4")
+    trie.AddEntry("+/+/humidity", "5")
+    trie.AddEntry("#", "6")
+
+    found := matchedFilters(trie, "sensors/kitchen/temp")
+    if found != "# sensors/# sensors/+/temp sensors/kitchen/temp" {
+        t.Errorf("Unexpected filters for kitchen temp: %s", found)
+    }
+    found = matchedFilters(trie, "sensors/hall/humidity")
+    if found != "# +/+/humidity sensors/#" {
+        t.Errorf("Unexpected filters for hall humidity: %s", found)
+    }
+    found = matchedFilters(trie, "sensors")
+    if found != "# sensors/#" {
+        t.Errorf("Multi-level wildcard should match the parent level: %s", found)
+    }
+    found = matchedFilters(trie, "sensors/kitchen/temp/max")
+    if found != "# sensors/#" {
+        t.Errorf("Single-level wildcard matched too deep: %s", found)
+    }
+}
+
+func TestTopicPartialLevels(t *testing.T) {
+    trie := NewTopicTrie('.')
+    trie.AddEntry("stock.+.nyse", "1")
+    trie.AddEntry("stock.us#", "2")
+    trie.AddEntry("stock.u+.nyse", "3")
+
+    found := matchedFilters(trie, "stock.usd.nyse")
+    if found != "stock.+.nyse" {
+        t.Errorf("Wildcards must occupy a whole level: %s", found)
+    }
+    found = matchedFilters(trie, "stock..nyse")
+    if found != "stock.+.nyse" {
+        t.Errorf("Single-level wildcard should match an empty level: %s", found)
+    }
+    for filter, value := range trie.MatchingFilters("stock.gbp.nyse") {
+        if filter != "stock.+.nyse" || value.(string) != "1" {
+            t.Errorf("Wrong value for %s", filter)
+        }
+    }
+}
+
+func TestTopicContext(t *testing.T) {
+    trie := NewTopicTrie('/')
+    trie.AddEntry("sensors/#", "1")
+    trie.AddEntry("#", "2")
+    found := 0
+    err := trie.MatchingFiltersContext(context.Background(), "sensors/hall", func(filter string, value interface{}) bool {
+        found++
+        return true
+    })
+    if err != nil || found != 2 {
+        t.Errorf("Expected 2 filters, got %d with %v", found, err)
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if err := trie.MatchingFiltersContext(ctx, "sensors/hall", func(string, interface{}) bool { return true }); err != context.Canceled {
+        t.Errorf("Expected a cancelled context to stop the match, got %v", err)
+    }
+}
+
+func TestTopicLoopWrites(t *testing.T) {
+    trie := NewTopicTrie('/')
+    trie.AddEntry("sensors/+", "1")
+    trie.AddEntry("#", "2")
+    for filter := range trie.MatchingFilters("sensors/hall") {
+        // used to deadlock, the trie was locked for the whole loop
+        trie.RemoveEntry(filter)
+    }
+    if trie.Len() != 0 {
+        t.Errorf("Expected every matching filter to be removed, %d left", trie.Len())
+    }
+}
+
+func TestTopicWildcardInTopic(t *testing.T) {
+    trie := NewTopicTrie('/')
+    trie.AddEntry("a/+", "1")
+    trie.AddEntry("a/#", "2")
+    trie.AddEntry("+/b", "3")
+    if found := matchedFilters(trie, "a/+"); found != "a/# a/+" {
+        t.Errorf("Unexpected filters for a/+: %s", found)
+    }
+    if found := matchedFilters(trie, "a/#"); found != "a/# a/+" {
+        t.Errorf("Unexpected filters for a/#: %s", found)
+    }
+    if found := matchedFilters(trie, "+/b"); found != "+/b" {
+        t.Errorf("Unexpected filters for +/b: %s", found)
+    }
+}
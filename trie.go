@@ -28,9 +28,11 @@ package trie
 import (
     //"strings"
     "fmt"
+    "sync"
+    "sync/atomic"
 )
 
-const startLetter = '.'
+const startLetter = ' ' // from space, so phrases stay clear of the wrapped indexes
 const endLetter = 'Z'
 const noLetters= int(endLetter) - int(startLetter) +1 // optimised for english... with a couple left over
 
@@ -38,31 +40,52 @@ type branch struct {
     children []*branch
     value interface{}
     shortcut []byte
+    count int // entries in this subtree, including this one
+    aggregate interface{} // only used with an Augment
+    mod uint64 // version of the last change in this subtree
 }
 
+// Trie is safe for concurrent use. Internally the lower case methods and
+// AddToBranch expect the caller to already hold lock.
+// The iterators only hold the lock while they collect the next batch of
+// entries, never while the body of the caller's loop runs.
 type Trie struct {
     tree *branch
-    unicodeMap map[int] int
-    nextIndex int
+    augment Augment
+    version uint64 // bumped by every change
+    watch watchers
+    id atomic.Uint64 // orders locking when two tries are locked together
+    lock sync.RWMutex
 }
 
+// GetKey maps a byte to its index in children. The bytes from startLetter
+// take the first slots so the common ones stay at the front and the ones
+// below it wrap around to the end, so children are in byte order once the
+// wrap is allowed for.
 func (this *Trie) GetKey(ch byte) int {
-    ir := int(ch)
-    index := -1
-    if ir >= startLetter && ir <= endLetter {
-        // we use it's key..
-        index = ir - startLetter
-    } else {
-        mapindex, exists := this.unicodeMap[ir]
-        if !exists {
-            index = this.nextIndex
-            this.nextIndex++
-            this.unicodeMap[ir] = index
-        } else {
-            index = mapindex
+    return int(ch - startLetter)
+}
+
+// keyByte is the reverse of GetKey
+func keyByte(index int) byte {
+    return byte(index) + startLetter
+}
+
+// step moves a position in the tree along by one byte. off is the number of
+// bytes of t.shortcut already consumed, so off == len(t.shortcut) means we
+// are sitting on t itself.
+func (this *Trie) step(t *branch, off int, ch byte) (*branch, int, bool) {
+    if off < len(t.shortcut) {
+        if t.shortcut[off] != ch {
+            return nil, 0, false
         }
+        return t, off+1, true
+    }
+    index := this.GetKey(ch)
+    if index > len(t.children)-1 || t.children[index] == nil {
+        return nil, 0, false
     }
-    return index
+    return t.children[index], 0, true
 }
 
 func (this *Trie) EnsureCapacity(children []*branch, index int) []*branch {
@@ -76,10 +99,37 @@ func (this *Trie) EnsureCapacity(children []*branch, index int) []*branch {
 
 func (this *Trie) AddEntry(entry string, value interface{}) {
     //entry = strings.ToUpper(entry)
-    this.AddToBranch(this.tree, []byte(entry), value)
+    defer this.dispatch()
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    // through update, so a nil value removes the entry and watchers only
+    // hear about real changes
+    this.update([]byte(entry), func(old interface{}, exists bool) (interface{}, bool) {
+        return value, value != nil
+    })
+}
+
+// refresh recalculates everything t keeps about its subtree, it needs to be
+// called on the way back up from any change below t
+func (this *Trie) refresh(t *branch) {
+    count := 0
+    if t.value != nil {
+        count = 1
+    }
+    for _, child := range t.children {
+        if child != nil {
+            count += child.count
+        }
+    }
+    t.count = count
+    t.mod = this.version
+    if this.augment != nil {
+        this.refreshAggregate(t)
+    }
 }
 
 func (this *Trie) AddToBranch(t *branch, remEntry []byte, value interface{}) {
+    defer this.refresh(t)
 
     // can we cheat?
     if t.shortcut == nil {
@@ -116,6 +166,9 @@ func (this *Trie) AddToBranch(t *branch, remEntry []byte, value interface{}) {
                 children: t.children,
                 value: t.value,
                 shortcut: ttail,
+                count: t.count,
+                aggregate: t.aggregate,
+                mod: t.mod,
             }
             t.children = make([]*branch, noLetters, noLetters)
             t.children = this.EnsureCapacity(t.children, tkey)
@@ -147,7 +200,56 @@ func (this *Trie) AddToBranch(t *branch, remEntry []byte, value interface{}) {
     }
 }
 
+// RemoveEntry deletes an entry, returning the value it had. Any branch left
+// without a value or a choice of children is folded back into a shortcut.
+func (this *Trie) RemoveEntry(entry string) (value interface{}, removed bool) {
+    defer this.dispatch()
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    this.update([]byte(entry), func(old interface{}, exists bool) (interface{}, bool) {
+        value, removed = old, exists
+        return nil, false
+    })
+    return value, removed
+}
+
+// collapse tidies up a branch that may have lost its value or a child
+func (this *Trie) collapse(t *branch) {
+    onlyChild, children := -1, 0
+    for y, child := range t.children {
+        if child == nil {
+            continue
+        }
+        if child.shortcut == nil && child.value == nil {
+            // emptied out, drop it
+            t.children[y] = nil
+            continue
+        }
+        onlyChild = y
+        children++
+    }
+    if t.value != nil || children > 1 {
+        return
+    }
+    if children == 0 {
+        // nothing left at all
+        t.shortcut = nil
+        t.children = nil
+        return
+    }
+    child := t.children[onlyChild]
+    shortcut := make([]byte, 0, len(t.shortcut)+1+len(child.shortcut))
+    shortcut = append(shortcut, t.shortcut...)
+    shortcut = append(shortcut, keyByte(onlyChild))
+    shortcut = append(shortcut, child.shortcut...)
+    t.shortcut = shortcut
+    t.children = child.children
+    t.value = child.value
+}
+
 func (this *Trie) DumpTree() {
+    this.lock.RLock()
+    defer this.lock.RUnlock()
     fmt.Printf("\n\n")
     this.DumpBranch(this.tree, 1)
 }
@@ -174,6 +276,12 @@ func (this *Trie) DumpBranch(t *branch, depth int) {
 }
 
 func (this *Trie) GetEntry(entry string) (value interface{}, validPath bool) {
+    this.lock.RLock()
+    defer this.lock.RUnlock()
+    return this.getEntry(entry)
+}
+
+func (this *Trie) getEntry(entry string) (value interface{}, validPath bool) {
     t := this.tree
     //entry = strings.ToUpper(entry)
     eb := []byte(entry)
@@ -193,19 +301,7 @@ func (this *Trie) GetEntry(entry string) (value interface{}, validPath bool) {
         x += y
         if x < len(eb) {
             // we got through the cheat!
-            index := -1
-            // we don't use GetKey here as we don't want reads to pollute our hashmap
-            ir := int(eb[x])
-            if ir >= startLetter && ir <= endLetter {
-                index = ir - startLetter
-            } else {
-                mapindex, exists := this.unicodeMap[ir]
-                if !exists {
-                    return nil, false // no mapping :/
-                } else {
-                    index = mapindex
-                }
-            }
+            index := this.GetKey(eb[x])
             if index > len(t.children)-1 || t.children[index] == nil {
                 return nil, false
             }
@@ -224,8 +320,6 @@ func NewTrie() *Trie {
             value: nil,
             shortcut: nil,
         },
-        unicodeMap: make(map[int]int),
-        nextIndex: 26,
     }
     return t
 }
@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "errors"
+
+// ErrConflict is returned when committing a transaction that touched keys
+// changed by someone else since it began
+var ErrConflict = errors.New("trie: transaction conflicts with a later change")
+
+// ErrTxnDone is returned when using a transaction that has already been
+// committed or discarded
+var ErrTxnDone = errors.New("trie: transaction already finished")
+
+// Batch collects puts and removals to apply to a trie in one go, with no
+// reader ever seeing part of it
+type Batch struct {
+    ops []batchOp
+}
+
+type batchOp struct {
+    key string
+    value interface{} // nil to remove
+}
+
+func NewBatch() *Batch {
+    return &Batch{}
+}
+
+func (this *Batch) Put(key string, value interface{}) {
+    this.ops = append(this.ops, batchOp{key, value})
+}
+
+func (this *Batch) Delete(key string) {
+    this.ops = append(this.ops, batchOp{key, nil})
+}
+
+// Len is the number of operations in the batch
+func (this *Batch) Len() int {
+    return len(this.ops)
+}
+
+// Apply makes every change in the batch, in order, as a single change to
+// the trie
+func (this *Trie) Apply(batch *Batch) {
+    defer this.dispatch()
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    this.apply(batch)
+}
+
+func (this *Trie) apply(batch *Batch) {
+    this.version++
+    for _, op := range batch.ops {
+        value := op.value
+        this.update([]byte(op.key), func(old interface{}, exists bool) (interface{}, bool) {
+            return value, value != nil
+        })
+    }
+}
+
+// changedSince reports whether anything with the given prefix may have
+// changed after version. Where the prefix runs off the tree we look at the
+// last branch it got to, as that is where a new key would have gone in.
+func (this *Trie) changedSince(prefix string, version uint64) bool {
+    t, off := this.tree, 0
+    for x := 0; x < len(prefix); x++ {
+        next, noff, ok := this.step(t, off, prefix[x])
+        if !ok {
+            break
+        }
+        t, off = next, noff
+    }
+    return t.mod > version
+}
+
+// Txn is a Batch that checks for conflicts when it commits. Every key it
+// reads or writes, along with any prefix passed to Guard, is watched from
+// the moment it begins, and if any of them changes in the trie before
+// Commit the whole transaction fails with ErrConflict.
+type Txn struct {
+    trie *Trie
+    start uint64
+    batch Batch
+    writes map[string]interface{}
+    guards map[string]bool
+    done bool
+}
+
+// Begin starts a transaction against the trie
+func (this *Trie) Begin() *Txn {
+    this.lock.RLock()
+    defer this.lock.RUnlock()
+    return &Txn {
+        trie: this,
+        start: this.version,
+        writes: make(map[string]interface{}),
+        guards: make(map[string]bool),
+    }
+}
+
+// Get reads a key, seeing the transaction's own writes
+func (this *Txn) Get(key string) interface{} {
+    this.guards[key] = true
+    if value, written := this.writes[key]; written {
+        return value
+    }
+    value, _ := this.trie.GetEntry(key)
+    return value
+}
+
+func (this *Txn) Put(key string, value interface{}) {
+    this.guards[key] = true
+    this.writes[key] = value
+    this.batch.Put(key, value)
+}
+
+func (this *Txn) Delete(key string) {
+    this.guards[key] = true
+    this.writes[key] = nil
+    this.batch.Delete(key)
+}
+
+// Guard watches every key starting with prefix for conflicts, such as keys
+// the transaction enumerated rather than read one by one
+func (this *Txn) Guard(prefix string) {
+    this.guards[prefix] = true
+}
+
+// Commit applies the transaction if nothing it watches has changed since it
+// began
+func (this *Txn) Commit() error {
+    if this.done {
+        return ErrTxnDone
+    }
+    this.done = true
+    defer this.trie.dispatch()
+    this.trie.lock.Lock()
+    defer this.trie.lock.Unlock()
+    for prefix := range this.guards {
+        if this.trie.changedSince(prefix, this.start) {
+            return ErrConflict
+        }
+    }
+    if this.batch.Len() > 0 {
+        this.trie.apply(&this.batch)
+    }
+    return nil
+}
+
+// Discard drops the transaction without applying it
+func (this *Txn) Discard() {
+    this.done = true
+}
@@ -0,0 +1,154 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+import "sync"
+
+func TestBatchIsAtomic(t *testing.T) {
+    trie := NewTrie()
+    trie.AddEntry("DICT OLD", 1)
+    var wg sync.WaitGroup
+    stop := make(chan struct{})
+    wg.Add(1)
+    go func() {
+        defer wg.Done()
+        for {
+            select {
+            case <-stop:
+                return
+            default:
+            }
+            // every batch replaces all of the keys, so a reader should only
+            // ever see one generation of them
+            seen := make(map[interface{}]bool)
+            for _, value := range trie.All() {
+                seen[value] = true
+            }
+            if len(seen) > 1 {
+                t.Errorf("Saw a partly applied batch: %v", seen)
+                return
+            }
+        }
+    }()
+    for generation := 2; generation < 50; generation++ {
+        batch := NewBatch()
+        batch.Delete("DICT OLD")
+        for x := 0; x < 20; x++ {
+            batch.Put(fmt.Sprintf("DICT %d", x), generation)
+        }
+        trie.Apply(batch)
+    }
+    close(stop)
+    wg.Wait()
+    if trie.Len() != 20 {
+        t.Errorf("Expected 20 entries, got %d", trie.Len())
+    }
+}
+
+func TestTxnConflicts(t *testing.T) {
+    trie := NewTrie()
+    trie.AddEntry("en/colour", "1")
+    trie.AddEntry("fr/couleur", "2")
+
+    a := trie.Begin()
+    b := trie.Begin()
+    if a.Get("en/colour").(string) != "1" {
+        t.Errorf("Transaction failed to read the trie")
+    }
+    a.Put("en/colour", "3")
+    if a.Get("en/colour").(string) != "3" {
+        t.Errorf("Transaction failed to read its own write")
+    }
+    b.Put("fr/couleur", "4")
+    if err := a.Commit(); err != nil {
+        t.Errorf("Unexpected error committing a: %s", err)
+    }
+    if err := b.Commit(); err != nil {
+        t.Errorf("Unrelated transactions conflicted: %s", err)
+    }
+    if err := b.Commit(); err != ErrTxnDone {
+        t.Errorf("Committed the same transaction twice")
+    }
+
+    c := trie.Begin()
+    d := trie.Begin()
+    c.Get("en/colour")
+    c.Put("en/color", "5")
+    d.Put("en/colour", "6")
+    d.Commit()
+    if err := c.Commit(); err != ErrConflict {
+        t.Errorf("Expected a conflict, got %v", err)
+    }
+    if value, _ := trie.GetEntry("en/color"); value != nil {
+        t.Errorf("Conflicting transaction was applied")
+    }
+
+    e := trie.Begin()
+    e.Guard("de/")
+    e.Put("en/summary", "none in german")
+    trie.AddEntry("de/farbe", "7")
+    if err := e.Commit(); err != ErrConflict {
+        t.Errorf("Expected a conflict on a new key under a guarded prefix, got %v", err)
+    }
+
+    f := trie.Begin()
+    f.Delete("fr/couleur")
+    f.Discard()
+    if err := f.Commit(); err != ErrTxnDone {
+        t.Errorf("Committed a discarded transaction")
+    }
+    if value, _ := trie.GetEntry("fr/couleur"); value.(string) != "4" {
+        t.Errorf("Discarded transaction was applied")
+    }
+}
+
+func TestTxnIgnoresNoOpWrites(t *testing.T) {
+    trie := NewTrie()
+    trie.AddEntry("k", 1)
+    tx := trie.Begin()
+    tx.Get("k")
+    tx.Put("other", 2)
+    if _, loaded := trie.LoadOrStore("k", 5); !loaded {
+        t.Errorf("LoadOrStore replaced an existing value")
+    }
+    if trie.CompareAndSwap("k", 7, 8) {
+        t.Errorf("CompareAndSwap swapped the wrong value")
+    }
+    trie.AddEntry("k", 1)
+    trie.RemoveEntry("missing")
+    if err := tx.Commit(); err != nil {
+        t.Errorf("Writes that changed nothing caused %v", err)
+    }
+
+    tx = trie.Begin()
+    tx.Get("k")
+    trie.CompareAndSwap("k", 1, 8)
+    if err := tx.Commit(); err != ErrConflict {
+        t.Errorf("Expected a real change to conflict, got %v", err)
+    }
+}
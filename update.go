@@ -0,0 +1,169 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+// trace follows entry down the tree as far as it matches. It returns every
+// branch passed through, ending with the one it stopped in, how far into
+// entry that last branch starts, how far into its shortcut we got and
+// whether the whole of entry was matched.
+func (this *Trie) trace(eb []byte) (path []*branch, start int, off int, matched bool) {
+    path = append(make([]*branch, 0, 8), this.tree)
+    t := this.tree
+    for x := 0; x < len(eb); x++ {
+        next, noff, ok := this.step(t, off, eb[x])
+        if !ok {
+            return path, start, off, false
+        }
+        if next != t {
+            path = append(path, next)
+            start = x+1
+        }
+        t, off = next, noff
+    }
+    return path, start, off, true
+}
+
+// update finds entry and replaces its value with whatever fn returns, all in
+// one trip down the tree. The path is only built if there's a value to put
+// at the end of it, and the entry is removed if fn doesn't keep it. If fn
+// leaves the value as it was nothing is touched, not even the version, so a
+// read that goes through update doesn't look like a write.
+func (this *Trie) update(eb []byte, fn func(old interface{}, exists bool) (interface{}, bool)) {
+    path, start, off, matched := this.trace(eb)
+    t := path[len(path)-1]
+    var old interface{}
+    if matched && off == len(t.shortcut) {
+        old = t.value
+    }
+    value, keep := fn(old, old != nil)
+    if !keep {
+        value = nil
+    }
+    if sameValue(old, value) {
+        return
+    }
+    this.version++
+    if this.watching() {
+        if value == nil {
+            this.notify(EventDelete, string(eb), old)
+        } else {
+            this.notify(EventPut, string(eb), value)
+        }
+    }
+    switch {
+    case old != nil:
+        t.value = value
+    default:
+        this.AddToBranch(t, eb[start:], value)
+    }
+    for x := len(path)-1; x >= 0; x-- {
+        if value == nil {
+            this.collapse(path[x])
+        }
+        this.refresh(path[x])
+    }
+}
+
+// Update replaces the value for key with the result of fn, which is given
+// the current value and whether there is one. If fn returns keep as false
+// the key is removed. fn is called with the trie locked, so it mustn't use
+// the trie itself.
+func (this *Trie) Update(key string, fn func(old interface{}, exists bool) (value interface{}, keep bool)) {
+    defer this.dispatch()
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    this.update([]byte(key), fn)
+}
+
+// LoadOrStore returns the existing value for key if there is one, otherwise
+// it stores value and returns that
+func (this *Trie) LoadOrStore(key string, value interface{}) (actual interface{}, loaded bool) {
+    this.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+        if exists {
+            actual, loaded = old, true
+            return old, true
+        }
+        actual = value
+        return value, true
+    })
+    return actual, loaded
+}
+
+// CompareAndSwap sets key to new only if its value is currently old, with an
+// old of nil meaning the key must not exist. Values are compared with ==, so
+// as with sync.Map they must be comparable.
+func (this *Trie) CompareAndSwap(key string, old interface{}, new interface{}) (swapped bool) {
+    this.Update(key, func(current interface{}, exists bool) (interface{}, bool) {
+        if current != old {
+            return current, exists
+        }
+        swapped = true
+        return new, true
+    })
+    return swapped
+}
+
+// Swap stores value for key, returning the value it replaced
+func (this *Trie) Swap(key string, value interface{}) (previous interface{}, loaded bool) {
+    this.Update(key, func(old interface{}, exists bool) (interface{}, bool) {
+        previous, loaded = old, exists
+        return value, true
+    })
+    return previous, loaded
+}
+
+// removeWhere removes every entry whose value fn picks out in a single pass
+// over the tree, returning how many went
+func (this *Trie) removeWhere(fn func(value interface{}) bool) int {
+    this.version++
+    before := this.tree.count
+    this.removeBelow(this.tree, nil, fn)
+    return before - this.tree.count
+}
+
+// key is the path down to t, not including its shortcut
+func (this *Trie) removeBelow(t *branch, key []byte, fn func(value interface{}) bool) bool {
+    changed := false
+    key = append(key, t.shortcut...)
+    for y, child := range t.children {
+        if child != nil && this.removeBelow(child, append(key, keyByte(y)), fn) {
+            changed = true
+        }
+    }
+    if t.value != nil && fn(t.value) {
+        if this.watching() {
+            this.notify(EventDelete, string(key), t.value)
+        }
+        t.value = nil
+        changed = true
+    }
+    if changed {
+        // leave untouched branches as they were, versions and all
+        this.collapse(t)
+        this.refresh(t)
+    }
+    return changed
+}
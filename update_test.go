@@ -0,0 +1,124 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "sync"
+import "fmt"
+
+func TestUpdate(t *testing.T) {
+    trie := NewTrie()
+    trie.AddEntry("shure asdf", 1)
+
+    increment := func(old interface{}, exists bool) (interface{}, bool) {
+        if !exists {
+            return 1, true
+        }
+        return old.(int)+1, true
+    }
+    trie.Update("shure", increment)
+    trie.Update("shure", increment)
+    trie.Update("shure asdf", increment)
+    if value, _ := trie.GetEntry("shure"); value.(int) != 2 {
+        t.Errorf("Expected shure to be 2, got %v", value)
+    }
+    if value, _ := trie.GetEntry("shure asdf"); value.(int) != 2 {
+        t.Errorf("Expected shure asdf to be 2, got %v", value)
+    }
+
+    trie.Update("shura", func(old interface{}, exists bool) (interface{}, bool) {
+        return nil, false
+    })
+    if _, validPath := trie.GetEntry("shura"); validPath {
+        t.Errorf("Path created for an update that kept nothing")
+    }
+    trie.Update("shure", func(old interface{}, exists bool) (interface{}, bool) {
+        return old, false
+    })
+    if value, _ := trie.GetEntry("shure"); value != nil || trie.Len() != 1 {
+        t.Errorf("Update failed to remove the entry")
+    }
+}
+
+func TestLoadOrStoreAndSwap(t *testing.T) {
+    trie := NewTrie()
+    actual, loaded := trie.LoadOrStore("ebay", "1")
+    if loaded || actual.(string) != "1" {
+        t.Errorf("LoadOrStore failed to store")
+    }
+    actual, loaded = trie.LoadOrStore("ebay", "2")
+    if !loaded || actual.(string) != "1" {
+        t.Errorf("LoadOrStore replaced an existing value")
+    }
+    if trie.CompareAndSwap("ebay", "2", "3") {
+        t.Errorf("Swapped on a mismatched value")
+    }
+    if !trie.CompareAndSwap("ebay", "1", "3") {
+        t.Errorf("Failed to swap on a matching value")
+    }
+    if !trie.CompareAndSwap("ebays", nil, "4") || trie.CompareAndSwap("ebays", nil, "5") {
+        t.Errorf("Compare against a missing entry is wrong")
+    }
+    previous, loaded := trie.Swap("ebay", "6")
+    if !loaded || previous.(string) != "3" {
+        t.Errorf("Swap returned the wrong previous value")
+    }
+    previous, loaded = trie.Swap("eba", "7")
+    if loaded || previous != nil {
+        t.Errorf("Swap of a new key returned a previous value")
+    }
+    for key, expected := range map[string]string {"eba": "7", "ebay": "6", "ebays": "4"} {
+        if value, _ := trie.GetEntry(key); value.(string) != expected {
+            t.Errorf("Expected %s for %s, got %v", expected, key, value)
+        }
+    }
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+    trie := NewTrie()
+    var wg sync.WaitGroup
+    for worker := 0; worker < 8; worker++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for x := 0; x < 500; x++ {
+                trie.Update(fmt.Sprintf("KEY %d", x%10), func(old interface{}, exists bool) (interface{}, bool) {
+                    if !exists {
+                        return 1, true
+                    }
+                    return old.(int)+1, true
+                })
+                trie.GetEntry("KEY 1")
+            }
+        }()
+    }
+    wg.Wait()
+    for key, value := range trie.All() {
+        if value.(int) != 400 {
+            t.Errorf("Lost updates to %s, got %d", key, value)
+        }
+    }
+}
@@ -0,0 +1,215 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "iter"
+    "sync"
+)
+
+// VersionedTrie keeps a history of its contents. Every commit makes a new
+// version, numbered one up from the last, and older versions can still be
+// read with At. Versions share every branch they have in common, a commit
+// only copies the branches on the paths to the keys it changes, so keeping
+// history costs little more than the changes themselves.
+type VersionedTrie struct {
+    lock sync.Mutex
+    retain int
+    first uint64 // the version held in roots[0]
+    roots []*branch
+}
+
+// TrieVersion is a read only view of a VersionedTrie as it was at one
+// version. It stays valid even once the version is no longer retained.
+type TrieVersion struct {
+    version uint64
+    trie *Trie
+}
+
+// NewVersionedTrie returns an empty trie at version 0 that keeps the last
+// retain versions, or all of them if retain is 0 or less
+func NewVersionedTrie(retain int) *VersionedTrie {
+    return &VersionedTrie {
+        retain: retain,
+        roots: []*branch{NewTrie().tree},
+    }
+}
+
+// Version is the number of the latest commit
+func (this *VersionedTrie) Version() uint64 {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    return this.first + uint64(len(this.roots)-1)
+}
+
+// Oldest is the number of the oldest version still retained
+func (this *VersionedTrie) Oldest() uint64 {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    return this.first
+}
+
+// Put sets key to value as a new version, returning its number
+func (this *VersionedTrie) Put(key string, value interface{}) uint64 {
+    batch := NewBatch()
+    batch.Put(key, value)
+    return this.Commit(batch)
+}
+
+// Delete removes key as a new version, returning its number
+func (this *VersionedTrie) Delete(key string) uint64 {
+    batch := NewBatch()
+    batch.Delete(key)
+    return this.Commit(batch)
+}
+
+// Commit applies every change in the batch as a single new version and
+// returns its number. A batch that changes nothing still makes a version.
+func (this *VersionedTrie) Commit(batch *Batch) uint64 {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    version := this.first + uint64(len(this.roots))
+    w := &Trie {
+        tree: this.roots[len(this.roots)-1],
+        version: version,
+    }
+    for _, op := range batch.ops {
+        w.cowUpdate([]byte(op.key), op.value)
+    }
+    this.roots = append(this.roots, w.tree)
+    if this.retain > 0 && len(this.roots) > this.retain {
+        drop := len(this.roots) - this.retain
+        // clear out the dropped roots so their branches can be collected
+        for x := 0; x < drop; x++ {
+            this.roots[x] = nil
+        }
+        this.roots = this.roots[drop:]
+        this.first += uint64(drop)
+    }
+    return version
+}
+
+// cowUpdate sets entry to value, or removes it if value is nil, without
+// changing any branch that is already part of a version. Every branch on the
+// path is copied first, and the copies are what change. Anything new below
+// the path is built from scratch, so nothing shared is ever touched.
+func (this *Trie) cowUpdate(eb []byte, value interface{}) {
+    path, start, off, matched := this.trace(eb)
+    t := path[len(path)-1]
+    var old interface{}
+    if matched && off == len(t.shortcut) {
+        old = t.value
+    }
+    if old == nil && value == nil {
+        return
+    }
+    for x, b := range path {
+        c := &branch {
+            children: append([]*branch(nil), b.children...),
+            value: b.value,
+            shortcut: b.shortcut,
+            count: b.count,
+            aggregate: b.aggregate,
+            mod: b.mod,
+        }
+        if x == 0 {
+            this.tree = c
+        } else {
+            parent := path[x-1]
+            for y, child := range parent.children {
+                if child == b {
+                    parent.children[y] = c
+                }
+            }
+        }
+        path[x] = c
+    }
+    t = path[len(path)-1]
+    if old != nil {
+        t.value = value
+    } else {
+        this.AddToBranch(t, eb[start:], value)
+    }
+    for x := len(path)-1; x >= 0; x-- {
+        if value == nil {
+            this.collapse(path[x])
+        }
+        this.refresh(path[x])
+    }
+}
+
+// At returns the trie as it was at version, or nil if that version hasn't
+// been committed yet or is no longer retained
+func (this *VersionedTrie) At(version uint64) *TrieVersion {
+    this.lock.Lock()
+    defer this.lock.Unlock()
+    if version < this.first || version-this.first >= uint64(len(this.roots)) {
+        return nil
+    }
+    return &TrieVersion {
+        version: version,
+        trie: &Trie{tree: this.roots[version-this.first]},
+    }
+}
+
+// Latest returns the trie as it is now
+func (this *VersionedTrie) Latest() *TrieVersion {
+    return this.At(this.Version())
+}
+
+// GetEntry looks key up in the latest version
+func (this *VersionedTrie) GetEntry(key string) (value interface{}, validPath bool) {
+    return this.Latest().GetEntry(key)
+}
+
+// Version is the number of the version this is a view of
+func (this *TrieVersion) Version() uint64 {
+    return this.version
+}
+
+func (this *TrieVersion) GetEntry(key string) (value interface{}, validPath bool) {
+    return this.trie.GetEntry(key)
+}
+
+func (this *TrieVersion) Len() int {
+    return this.trie.Len()
+}
+
+func (this *TrieVersion) All() iter.Seq2[string, interface{}] {
+    return this.trie.All()
+}
+
+func (this *TrieVersion) EntriesWithPrefix(prefix string) iter.Seq2[string, interface{}] {
+    return this.trie.EntriesWithPrefix(prefix)
+}
+
+// DiffVersions yields the changes between two versions in key order, as Diff
+// does for tries. Versions share every branch a commit didn't touch, and
+// those are skipped without being looked at, so the cost depends on how much
+// changed between them rather than on the size of the trie.
+func DiffVersions(old *TrieVersion, new *TrieVersion) iter.Seq[Change] {
+    return Diff(old.trie, new.trie)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+
+func TestVersionedTrieHistory(t *testing.T) {
+    trie := NewVersionedTrie(0)
+    v1 := trie.Put("colour", "red")
+    v2 := trie.Put("colours", "many")
+    v3 := trie.Put("colour", "blue")
+    v4 := trie.Delete("colour")
+    batch := NewBatch()
+    batch.Put("col", "short")
+    batch.Delete("colours")
+    v5 := trie.Commit(batch)
+    if v1 != 1 || v2 != 2 || v3 != 3 || v4 != 4 || v5 != 5 {
+        t.Errorf("Expected versions 1 to 5, got %d %d %d %d %d", v1, v2, v3, v4, v5)
+    }
+
+    expected := []map[string]interface{} {
+        {},
+        {"colour": "red"},
+        {"colour": "red", "colours": "many"},
+        {"colour": "blue", "colours": "many"},
+        {"colours": "many"},
+        {"col": "short"},
+    }
+    for version, entries := range expected {
+        at := trie.At(uint64(version))
+        if at.Len() != len(entries) {
+            t.Errorf("Version %d has %d entries, expected %d", version, at.Len(), len(entries))
+        }
+        for key, value := range at.All() {
+            if entries[key] != value {
+                t.Errorf("Version %d has %s = %v, expected %v", version, key, value, entries[key])
+            }
+        }
+    }
+    if value, _ := trie.At(v3).GetEntry("colour"); value != "blue" {
+        t.Errorf("Expected blue at version %d, got %v", v3, value)
+    }
+    if trie.At(6) != nil {
+        t.Errorf("Got a version that hasn't been committed")
+    }
+}
+
+func TestVersionedTrieRetention(t *testing.T) {
+    trie := NewVersionedTrie(10)
+    for x := 0; x < 100; x++ {
+        trie.Put(fmt.Sprintf("key %d", x % 7), x)
+    }
+    if trie.Version() != 100 || trie.Oldest() != 91 {
+        t.Errorf("Expected versions 91 to 100, got %d to %d", trie.Oldest(), trie.Version())
+    }
+    if trie.At(90) != nil {
+        t.Errorf("Version 90 should have been dropped")
+    }
+    for version := uint64(91); version <= 100; version++ {
+        // version v was the put of v-1
+        key := fmt.Sprintf("key %d", (version-1) % 7)
+        if value, _ := trie.At(version).GetEntry(key); value != int(version-1) {
+            t.Errorf("Expected %s = %d at version %d, got %v", key, version-1, version, value)
+        }
+    }
+}
+
+func TestVersionedDiff(t *testing.T) {
+    trie := NewVersionedTrie(0)
+    batch := NewBatch()
+    for x := 0; x < 200; x++ {
+        // funcs are never DeepEqual, so any of these being compared would
+        // turn up as a change
+        batch.Put(fmt.Sprintf("shared/%03d", x), func() {})
+    }
+    batch.Put("changing/colour", "red")
+    batch.Put("changing/gone", "soon")
+    old := trie.Commit(batch)
+    batch = NewBatch()
+    batch.Put("changing/colour", "blue")
+    batch.Delete("changing/gone")
+    batch.Put("changing/new", "here")
+    new := trie.Commit(batch)
+
+    var changes []Change
+    for change := range DiffVersions(trie.At(old), trie.At(new)) {
+        changes = append(changes, change)
+    }
+    expected := []Change {
+        {ChangeModified, "changing/colour", "red", "blue"},
+        {ChangeRemoved, "changing/gone", "soon", nil},
+        {ChangeAdded, "changing/new", nil, "here"},
+    }
+    if fmt.Sprint(changes) != fmt.Sprint(expected) {
+        t.Errorf("Expected %v, got %v", expected, changes)
+    }
+}
@@ -0,0 +1,162 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import (
+    "reflect"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+// WatchBuffer is how many events a watcher can fall behind by before new
+// ones are dropped
+const WatchBuffer = 64
+
+type EventType int
+
+const (
+    EventPut EventType = iota
+    EventDelete
+)
+
+func (this EventType) String() string {
+    switch this {
+    case EventPut:
+        return "put"
+    case EventDelete:
+        return "delete"
+    }
+    return "unknown"
+}
+
+// Event is a change to one key. Value is the new value for a put and the
+// value that was removed for a delete. Dropped is how many events for this
+// watcher were thrown away, since the last one it got, because its buffer
+// was full; once it is non zero anything cached from the trie should be
+// treated as stale.
+type Event struct {
+    Type EventType
+    Key string
+    Value interface{}
+    Dropped int
+}
+
+// watchers holds everyone watching a trie along with the events waiting to
+// go out to them. Events are queued with the trie locked and sent once it
+// has been unlocked, so nothing is held up by a slow reader.
+type watchers struct {
+    lock sync.Mutex
+    count atomic.Int32
+    subs []*subscription
+    queue []Event
+}
+
+type subscription struct {
+    prefix string
+    ch chan Event
+    dropped int
+}
+
+// Watch returns a channel of the puts and deletes made to keys starting with
+// prefix, along with a function that stops the watch and closes the
+// channel. Events arrive in the order the changes were made. Sends never
+// block: when a watcher already has WatchBuffer events waiting, newer ones
+// are dropped and the count is passed on in the Dropped field of the next
+// event that fits.
+func (this *Trie) Watch(prefix string) (<-chan Event, func()) {
+    sub := &subscription {
+        prefix: prefix,
+        ch: make(chan Event, WatchBuffer),
+    }
+    w := &this.watch
+    w.lock.Lock()
+    w.subs = append(w.subs, sub)
+    w.count.Add(1)
+    w.lock.Unlock()
+    var once sync.Once
+    return sub.ch, func() {
+        once.Do(func() {
+            w.lock.Lock()
+            defer w.lock.Unlock()
+            for x, s := range w.subs {
+                if s == sub {
+                    w.subs = append(w.subs[:x:x], w.subs[x+1:]...)
+                    break
+                }
+            }
+            w.count.Add(-1)
+            close(sub.ch)
+        })
+    }
+}
+
+// watching is a quick check for whether notify needs to be called at all
+func (this *Trie) watching() bool {
+    return this.watch.count.Load() > 0
+}
+
+// notify queues an event to go out when dispatch is next called, the trie
+// should be locked
+func (this *Trie) notify(typ EventType, key string, value interface{}) {
+    w := &this.watch
+    w.lock.Lock()
+    defer w.lock.Unlock()
+    w.queue = append(w.queue, Event{Type: typ, Key: key, Value: value})
+}
+
+// dispatch sends out any queued events. It has to be called after the trie
+// is unlocked, so is usually deferred before the lock is taken.
+func (this *Trie) dispatch() {
+    w := &this.watch
+    w.lock.Lock()
+    defer w.lock.Unlock()
+    for _, event := range w.queue {
+        for _, sub := range w.subs {
+            if !strings.HasPrefix(event.Key, sub.prefix) {
+                continue
+            }
+            event.Dropped = sub.dropped
+            select {
+            case sub.ch <- event:
+                sub.dropped = 0
+            default:
+                sub.dropped++
+            }
+        }
+    }
+    clear(w.queue)
+    w.queue = w.queue[:0]
+}
+
+// sameValue reports whether putting value over old changes nothing, without
+// panicking on values that can't be compared
+func sameValue(old interface{}, value interface{}) bool {
+    if old == nil || value == nil {
+        return old == value
+    }
+    return reflect.TypeOf(old) == reflect.TypeOf(value) && reflect.ValueOf(old).Comparable() && old == value
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2012, Richard Johnson
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without modification,
+are permitted provided that the following conditions are met:
+
+ - Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+ - Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS" AND
+ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE IMPLIED
+WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE
+FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR
+SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY,
+OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+*/
+
+package trie
+
+import "testing"
+import "fmt"
+
+func TestWatchPrefix(t *testing.T) {
+    trie := NewTrie()
+    events, cancel := trie.Watch("en/")
+    trie.AddEntry("en/colour", "red")
+    trie.AddEntry("fr/couleur", "rouge")
+    trie.AddEntry("en/colour", "red") // no change, so no event
+    trie.AddEntry("en/shade", "dark")
+    trie.AddEntry("en/shade", nil)
+    trie.LoadOrStore("en/colour", "blue") // no change, so no event
+    trie.Swap("en/colour", "blue")
+    trie.RemoveEntry("en/colour")
+    trie.RemoveEntry("en/missing")
+    other := NewTrie()
+    other.AddEntry("en/flavour", "sweet")
+    other.AddEntry("en/flavours", "many")
+    trie.MergeFrom(other, nil)
+    batch := NewBatch()
+    batch.Delete("en/flavour")
+    trie.Apply(batch)
+    cancel()
+    cancel()
+    trie.AddEntry("en/after", "cancelled")
+
+    expected := []Event {
+        {EventPut, "en/colour", "red", 0},
+        {EventPut, "en/shade", "dark", 0},
+        {EventDelete, "en/shade", "dark", 0},
+        {EventPut, "en/colour", "blue", 0},
+        {EventDelete, "en/colour", "blue", 0},
+        {EventPut, "en/flavour", "sweet", 0},
+        {EventPut, "en/flavours", "many", 0},
+        {EventDelete, "en/flavour", "sweet", 0},
+    }
+    x := 0
+    for event := range events {
+        if x >= len(expected) {
+            t.Errorf("Unexpected event %v", event)
+        } else if event != expected[x] {
+            t.Errorf("Expected %v, got %v", expected[x], event)
+        }
+        x++
+    }
+    if x != len(expected) {
+        t.Errorf("Expected %d events, got %d", len(expected), x)
+    }
+}
+
+func TestWatchOverflow(t *testing.T) {
+    counter := NewCounter()
+    events, cancel := counter.counts.Watch("")
+    defer cancel()
+    for x := 0; x < WatchBuffer+10; x++ {
+        counter.Inc(fmt.Sprintf("word%d", x), 1)
+    }
+    for x := 0; x < WatchBuffer; x++ {
+        <-events
+    }
+    counter.Prune(2)
+    event := <-events
+    if event.Type != EventDelete || event.Dropped != 10 {
+        t.Errorf("Expected a delete after 10 dropped events, got %v", event)
+    }
+    if len(events) != WatchBuffer-1 {
+        t.Errorf("Expected a full buffer, got %d events", len(events))
+    }
+}